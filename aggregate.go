@@ -0,0 +1,411 @@
+package dbx
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 聚合函数，用于 Select()：
+//
+//	db.Table("order").Select("gid", dbx.Count("*").As("n"), dbx.Sum("amount")).GroupBy("gid").Having("n>?", 1).All(&list)
+//
+// 没有 As() 时列名为 count / sum_amount / avg_amount ...
+type Aggregate struct {
+	Func  string // COUNT / SUM / AVG / MAX / MIN
+	Col   string
+	Alias string
+}
+
+func Count(colName string) Aggregate { return Aggregate{"COUNT", colName, ""} }
+func Sum(colName string) Aggregate   { return Aggregate{"SUM", colName, ""} }
+func Avg(colName string) Aggregate   { return Aggregate{"AVG", colName, ""} }
+func Max(colName string) Aggregate   { return Aggregate{"MAX", colName, ""} }
+func Min(colName string) Aggregate   { return Aggregate{"MIN", colName, ""} }
+
+func (a Aggregate) As(alias string) Aggregate {
+	a.Alias = alias
+	return a
+}
+
+// 结果中的列名
+func (a Aggregate) name() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	if a.Col == "*" {
+		return strings.ToLower(a.Func)
+	}
+	return strings.ToLower(a.Func) + "_" + strings.Replace(a.Col, ".", "_", -1)
+}
+
+func (a Aggregate) toSQL(isCQL bool) string {
+	col := a.Col
+	if col != "*" {
+		check_ident("column", col)
+		col = quote_ident(col, isCQL)
+	}
+	check_ident("alias", a.name())
+	return fmt.Sprintf("%v(%v) AS %v", a.Func, col, quote_ident(a.name(), isCQL))
+}
+
+// SELECT 的列，参数为列名或者 Aggregate
+func (q *Query) Select(fields ...interface{}) *Query {
+	for _, f := range fields {
+		switch f.(type) {
+		case string, Aggregate:
+		default:
+			q.Panic("Select(): expect column name or dbx.Aggregate, got %T", f)
+		}
+	}
+	q = q.Clone()
+	q.selects = append(q.selects, fields...)
+	return q
+}
+
+func (q *Query) GroupBy(colNames ...string) *Query {
+	q = q.Clone()
+	q.groupBy = append(q.groupBy, colNames...)
+	return q
+}
+
+// 针对分组的条件，可以使用聚合的列名：Having("n>?", 1)
+func (q *Query) Having(str string, args ...interface{}) *Query {
+	q = q.Clone()
+	if q.having == "" {
+		q.having = str
+	} else {
+		q.having += " AND " + str
+	}
+	q.havingArgs = append(q.havingArgs, args...)
+	return q
+}
+
+func (q *Query) selectsToSQL() string {
+	arr := make([]string, 0, len(q.selects))
+	for _, f := range q.selects {
+		switch v := f.(type) {
+		case string:
+			arr = append(arr, q.colSQL(nil, v))
+		case Aggregate:
+			arr = append(arr, v.toSQL(q.isCQL))
+		}
+	}
+	return strings.Join(arr, ",")
+}
+
+// GROUP BY 和 HAVING 部分
+func (q *Query) groupByToSQL() (sql1 string, args []interface{}) {
+	if len(q.groupBy) > 0 {
+		arr := make([]string, len(q.groupBy))
+		for i, colName := range q.groupBy {
+			arr[i] = q.colSQL(nil, colName)
+		}
+		sql1 = " GROUP BY " + strings.Join(arr, ",")
+	}
+	if q.having != "" {
+		sql1 += " HAVING " + q.having
+		args = q.havingArgs
+	}
+	return
+}
+
+func (q *Query) has_aggregate() bool {
+	if len(q.groupBy) > 0 {
+		return true
+	}
+	for _, f := range q.selects {
+		if _, ok := f.(Aggregate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 结果与表的结构不同，读入 getResultTableStruct()
+func (q *Query) is_projection() bool {
+	return len(q.joins) > 0 || q.has_aggregate()
+}
+
+// 平均值，结果为 float64；Sum() / Max() / Min() 为 int64，浮点数请使用 Select(dbx.Sum("amount"))
+func (q *Query) Avg(colName string) (f float64, err error) {
+	defer dbxErrorDefer(&err, q)
+	q2 := *q
+	q2.selects = []interface{}{Avg(colName).As("avg")}
+	q2.fields = nil
+	var list []map[string]interface{}
+	list, err = q2.all_maps()
+	if err != nil || len(list) == 0 {
+		return
+	}
+	f, _ = to_float64(normalize_value(list[0]["avg"]))
+	return
+}
+
+// 按照列名赋值到 &struct，不存在的列跳过
+func values_to_struct(tableStruct *TableStruct, columns []string, values []interface{}, row reflect.Value) {
+	for i, colName := range columns {
+		col := tableStruct.ColFieldMap.GetByColName(colName)
+		if col == nil {
+			continue
+		}
+		set_col_value(tableStruct, col, get_reflect_field_from_pos(row.Elem(), col.FieldPos), values[i])
+	}
+}
+
+var mapType = reflect.TypeOf(map[string]interface{}{})
+
+// n>? / sum_amount >= ?
+var havingRegexp = regexp.MustCompile(`^\s*(\w+)\s*(=|!=|<>|>=|<=|>|<)\s*\?\s*$`)
+
+// 开启缓存的表，在内存中执行 GROUP BY 和聚合；条件中有 SQL 字符串等不能执行的情况 ok 为 false
+func (q *Query) memory_aggregate() (columns []string, rows [][]interface{}, ok bool) {
+	if !q.has_aggregate() {
+		return
+	}
+	tableStruct := q.getTableStruct()
+	if !q.memory_enabled(tableStruct) {
+		return
+	}
+
+	// 结果的列：普通列取分组中第一行的值
+	cols := make([]*Col, len(q.selects))
+	for i, f := range q.selects {
+		colName := ""
+		switch v := f.(type) {
+		case string:
+			colName = v
+			columns = append(columns, v)
+		case Aggregate:
+			colName = v.Col
+			columns = append(columns, v.name())
+		}
+		if colName == "*" {
+			continue
+		}
+		if cols[i] = tableStruct.ColFieldMap.GetByColName(colName); cols[i] == nil {
+			return nil, nil, false
+		}
+	}
+	if len(columns) == 0 {
+		return nil, nil, false
+	}
+	groupCols := make([]*Col, len(q.groupBy))
+	for i, colName := range q.groupBy {
+		if groupCols[i] = tableStruct.ColFieldMap.GetByColName(colName); groupCols[i] == nil {
+			return nil, nil, false
+		}
+	}
+
+	// HAVING 只支持 "列名 运算符 ?"
+	havingArr := []string{}
+	if q.having != "" {
+		havingArr = strings.Split(q.having, " AND ")
+		if len(havingArr) != len(q.havingArgs) {
+			return nil, nil, false
+		}
+	}
+	havingIdx := make([]int, len(havingArr))
+	havingOps := make([]string, len(havingArr))
+	for i, s := range havingArr {
+		m := havingRegexp.FindStringSubmatch(s)
+		if m == nil {
+			return nil, nil, false
+		}
+		if havingIdx[i] = index_of(columns, m[1]); havingIdx[i] == -1 {
+			return nil, nil, false
+		}
+		havingOps[i] = m[2]
+	}
+
+	// 排序：默认按照分组的列
+	orderBy := q.orderBy
+	if len(orderBy) == 0 {
+		for _, colName := range q.groupBy {
+			orderBy = append(orderBy, Map{colName, 1})
+		}
+	}
+	orderIdx := make([]int, 0, len(orderBy))
+	orderOpts := make([][2]int, 0, len(orderBy))
+	for _, m := range orderBy {
+		i := index_of(columns, m.Key)
+		if i == -1 {
+			if len(q.orderBy) > 0 {
+				return nil, nil, false
+			}
+			continue
+		}
+		order, nulls := sort_order(m.Value)
+		orderIdx = append(orderIdx, i)
+		orderOpts = append(orderOpts, [2]int{order, nulls})
+	}
+
+	// 分组
+	groups := map[string][]reflect.Value{}
+	keys := []string{}
+	if len(q.groupBy) == 0 {
+		// 没有 GROUP BY 时总是返回一行
+		keys = append(keys, "")
+		groups[""] = []reflect.Value{}
+	}
+	q.tableData[q.table].Range(func(k, v interface{}) bool {
+		row := reflect.ValueOf(v).Elem()
+		if !q.memory_match(tableStruct, row) {
+			return true
+		}
+		key := ""
+		for _, col := range groupCols {
+			key += fmt.Sprintf("%v%v", get_value_from_pos(row, col.FieldPos), KEY_SEP)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+		return true
+	})
+
+	for _, key := range keys {
+		group := groups[key]
+		values := make([]interface{}, len(columns))
+		for i, f := range q.selects {
+			switch v := f.(type) {
+			case string:
+				if len(group) > 0 {
+					values[i] = col_db_value(cols[i], get_value_from_pos(group[0], cols[i].FieldPos), q.isCQL)
+				}
+			case Aggregate:
+				values[i] = aggregate_values(v, cols[i], group, q.isCQL)
+			}
+		}
+		match := true
+		for i, idx := range havingIdx {
+			if !compare_op(values[idx], havingOps[i], q.havingArgs[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			rows = append(rows, values)
+		}
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		for k, i := range orderIdx {
+			if n := q.compare_sort(rows[a][i], rows[b][i], orderOpts[k][0], orderOpts[k][1]); n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+	start, end := q.limit_bounds(len(rows))
+	return columns, rows[start:end], true
+}
+
+// 与 SQL 一致：COUNT 不计 NULL，SUM / AVG / MAX / MIN 没有值时为 NULL；整数的 SUM 为 int64，其他为 float64；
+// sql.NullInt64 等按照写入数据库的值计算，MAX / MIN 返回与 SQL 相同的类型
+func aggregate_values(a Aggregate, col *Col, group []reflect.Value, isCQL bool) interface{} {
+	if col == nil {
+		if a.Func == "COUNT" {
+			return int64(len(group))
+		}
+		return nil
+	}
+	n := int64(0)
+	isInt := true
+	sumInt := int64(0)
+	sumFloat := float64(0)
+	var ret interface{}
+	for _, row := range group {
+		v := col_db_value(col, get_value_from_pos(row, col.FieldPos), isCQL)
+		if v == nil {
+			continue
+		}
+		n++
+		switch a.Func {
+		case "SUM", "AVG":
+			switch vv := v.(type) {
+			case int64:
+				sumInt += vv
+			case bool:
+				if vv {
+					sumInt++
+				}
+			default:
+				isInt = false
+				f, _ := to_float64(normalize_value(v))
+				sumFloat += f
+			}
+		case "MAX", "MIN":
+			if ret == nil {
+				ret = v
+				continue
+			}
+			c, _ := compare_values(v, ret)
+			if (a.Func == "MAX" && c > 0) || (a.Func == "MIN" && c < 0) {
+				ret = v
+			}
+		}
+	}
+	switch a.Func {
+	case "COUNT":
+		return n
+	case "SUM":
+		if n == 0 {
+			return nil
+		}
+		if isInt {
+			return sumInt
+		}
+		return sumFloat + float64(sumInt)
+	case "AVG":
+		if n == 0 {
+			return nil
+		}
+		return (sumFloat + float64(sumInt)) / float64(n)
+	}
+	return ret
+}
+
+// 字段的值转换为数据库返回的值：NULL 为 nil，JSON 列为字符串，其他经过 value_to_arg() 后由 driver_value() 统一类型
+func col_db_value(col *Col, v interface{}, isCQL bool) interface{} {
+	if v == nil {
+		return nil
+	}
+	if col.JSON {
+		return json_marshal(v)
+	}
+	return driver_value(value_to_arg(v, isCQL))
+}
+
+// 统一数据库与缓存中的值的类型：整数为 int64，浮点数为 float64，[]byte 为 string，其他不变
+func driver_value(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+	}
+	return v
+}
+
+func index_of(arr []string, s string) int {
+	for i, v := range arr {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
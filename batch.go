@@ -0,0 +1,581 @@
+package dbx
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/xiuno/dbx/lib/syncmap"
+)
+
+// 批量插入，一条 SQL 插入多行：
+//
+//	ids, err := db.Table("user").InsertBatch(list)
+//	ids, err := db.Table("user").InsertBatch(list, dbx.BatchOpts{Size: 200, Ignore: true})
+//
+// MySQL / SQLite 为 INSERT INTO t (...) VALUES (...),(...)，Cassandra 为 BEGIN UNLOGGED BATCH ... APPLY BATCH。
+type BatchOpts struct {
+	Size    int  // 每条 SQL 最多的行数，默认 1000，Cassandra 默认 100
+	MaxArgs int  // 每条 SQL 最多的参数个数，默认 MySQL 65535、SQLite 999（3.32 以后为 32766，可以调大）
+	Ignore  bool // 跳过主键、唯一索引冲突的行，不返回自增 ID，开启缓存时重新加载整个表
+
+	// 冲突时更新，与 Upsert() 相同，不返回自增 ID，开启缓存时重新加载整个表；Cassandra 覆盖插入的所有列
+	OnConflict *Conflict
+}
+
+const (
+	BATCH_SIZE         = 1000
+	BATCH_SIZE_CQL     = 100
+	BATCH_ARGS_MYSQL   = 65535
+	BATCH_ARGS_SQLITE  = 999
+	BATCH_ARGS_DEFAULT = 65535
+)
+
+func (q *Query) batch_limits(opt BatchOpts) (size int, maxArgs int) {
+	size, maxArgs = opt.Size, opt.MaxArgs
+	if size <= 0 {
+		size = BATCH_SIZE
+		if q.isCQL {
+			size = BATCH_SIZE_CQL
+		}
+	}
+	if maxArgs <= 0 {
+		switch q.DriverType {
+		case DRIVER_MYSQL:
+			maxArgs = BATCH_ARGS_MYSQL
+		case DRIVER_SQLITE:
+			maxArgs = BATCH_ARGS_SQLITE
+		default:
+			maxArgs = BATCH_ARGS_DEFAULT
+		}
+	}
+	return
+}
+
+// list 为 []T、[]*T 或者它们的指针，返回按顺序的自增 ID（没有自增列、Cassandra、Ignore、OnConflict 时为 nil），
+// 自增列的值同时写回 list 中的行。
+// 按照 Size、MaxArgs 分成多条 SQL，不在事务中执行，出错时返回已经插入的行的 ID。
+// MySQL 依赖同一条 INSERT 的自增 ID 连续，innodb_autoinc_lock_mode=2 并且有并发插入时不能保证。
+func (q *Query) InsertBatch(list interface{}, opts ...BatchOpts) (ids []int64, err error) {
+	if q.readOnly {
+		return
+	}
+	defer dbxErrorDefer(&err, q)
+
+	var opt BatchOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Ignore && q.isCQL {
+		q.Panic("InsertBatch(): Cassandra does not support Ignore")
+	}
+	if opt.Ignore && opt.OnConflict != nil {
+		q.Panic("InsertBatch(): Ignore can not be used with OnConflict")
+	}
+
+	listValue := reflect.ValueOf(list)
+	if listValue.Kind() == reflect.Ptr {
+		listValue = listValue.Elem()
+	}
+	if listValue.Kind() != reflect.Slice {
+		q.Panic("InsertBatch(): must pass a slice: %T", list)
+	}
+	elemType := listValue.Type().Elem()
+	elemIsPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if elemIsPtr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		q.Panic("InsertBatch(): slice element must be a struct or a struct pointer: %v", elemType)
+	}
+	tableStruct := q.getTableStruct(reflect.New(structType).Type())
+
+	// 统一为 *struct，slice 的元素可以取地址，自增 ID 直接写回
+	rows := make([]reflect.Value, listValue.Len())
+	for i := range rows {
+		row := listValue.Index(i)
+		if elemIsPtr {
+			if row.IsNil() {
+				q.Panic("InsertBatch(): nil row at index %v", i)
+			}
+		} else {
+			row = row.Addr()
+		}
+		rows[i] = row
+	}
+	ids, _, err = q.insert_batch_rows(tableStruct, rows, elemIsPtr, opt, false)
+
+	// INSERT IGNORE、冲突时更新，不知道哪些行被插入或者修改，重新加载缓存
+	if (opt.Ignore || opt.OnConflict != nil) && q.tableEnableCache && tableStruct.EnableCache {
+		q.loadTableCache(q.table)
+	}
+	return
+}
+
+// rows 为 *struct，done 为出错之前已经写入的行数；keepAutoIncrement 时自增列有值的行插入该值（导入）
+func (q *Query) insert_batch_rows(tableStruct *TableStruct, rows []reflect.Value, elemIsPtr bool, opt BatchOpts, keepAutoIncrement bool) (ids []int64, done int, err error) {
+	var autoPos []int
+	if tableStruct.AutoIncrement != "" {
+		autoPos = tableStruct.ColFieldMap.GetByColName(tableStruct.AutoIncrement).FieldPos
+	}
+	if autoPos != nil && !q.isCQL && !opt.Ignore && opt.OnConflict == nil {
+		ids = make([]int64, 0, len(rows))
+	}
+	size, maxArgs := q.batch_limits(opt)
+
+	// 列相同的相邻行放到一条 SQL 中，omitempty、default 可能使每行的列不同
+	var colNames0 []string
+	var chunk []reflect.Value
+	var args []interface{}
+	for _, row := range rows {
+		withAutoIncrement := keepAutoIncrement && autoPos != nil && !is_zero(get_value_from_pos(row.Elem(), autoPos))
+		colNames, rowArgs, _, _ := struct_value_to_args(tableStruct, row.Elem(), !withAutoIncrement, false, q.isCQL, true)
+		if len(rowArgs) > maxArgs {
+			q.Panic("InsertBatch(): %v columns exceeds MaxArgs %v", len(rowArgs), maxArgs)
+		}
+		if len(chunk) > 0 && (len(chunk) >= size || len(args)+len(rowArgs) > maxArgs || !str_arr_equal(colNames, colNames0)) {
+			if ids, err = q.insert_batch_chunk(tableStruct, colNames0, chunk, args, opt, elemIsPtr, ids); err != nil {
+				return
+			}
+			done += len(chunk)
+			chunk, args = nil, nil
+		}
+		colNames0 = colNames
+		chunk = append(chunk, row)
+		args = append(args, rowArgs...)
+	}
+	if len(chunk) > 0 {
+		if ids, err = q.insert_batch_chunk(tableStruct, colNames0, chunk, args, opt, elemIsPtr, ids); err != nil {
+			return
+		}
+		done += len(chunk)
+	}
+	return
+}
+
+func (q *Query) insert_batch_chunk(tableStruct *TableStruct, colNames []string, rows []reflect.Value, args []interface{}, opt BatchOpts, elemIsPtr bool, ids []int64) ([]int64, error) {
+	fields := arr_to_sql_add(colNames, "", ",", q.isCQL)
+	values := "(" + strings.TrimRight(strings.Repeat("?,", len(colNames)), ",") + ")"
+	var sql1 string
+	if q.isCQL {
+		// 不同分区的 LWT 不能放在一个 BATCH 中，所以没有 IF NOT EXISTS
+		insert := fmt.Sprintf("INSERT INTO %v (%v) VALUES %v; ", q.tableSQL(), fields, values)
+		sql1 = "BEGIN UNLOGGED BATCH " + strings.Repeat(insert, len(rows)) + "APPLY BATCH"
+	} else {
+		insert := "INSERT INTO"
+		if opt.Ignore && q.DriverType == DRIVER_MYSQL {
+			insert = "INSERT IGNORE INTO"
+		} else if opt.Ignore {
+			insert = "INSERT OR IGNORE INTO"
+		}
+		values = strings.TrimRight(strings.Repeat(values+",", len(rows)), ",")
+		sql1 = fmt.Sprintf("%v %v (%v) VALUES %v", insert, q.tableSQL(), fields, values)
+		if opt.OnConflict != nil {
+			target, update := q.conflict_columns(tableStruct, colNames, opt.OnConflict)
+			sql1 += q.conflict_clause(target, update, "")
+		}
+	}
+
+	lastId, err := q.Exec(sql1, args...)
+	if err != nil {
+		return ids, err
+	}
+
+	// MySQL 返回第一行的 ID，SQLite 返回最后一行的 ID；插入了自增列时为行中的值
+	if ids != nil {
+		pos := tableStruct.ColFieldMap.GetByColName(tableStruct.AutoIncrement).FieldPos
+		if in_array(tableStruct.AutoIncrement, colNames) {
+			for _, row := range rows {
+				id := reflect.New(reflect.TypeOf(int64(0))).Elem()
+				assign_value(id, get_value_from_pos(row.Elem(), pos))
+				ids = append(ids, id.Int())
+			}
+		} else {
+			firstId := lastId
+			if q.DriverType == DRIVER_SQLITE {
+				firstId = lastId - int64(len(rows)) + 1
+			}
+			for i, row := range rows {
+				id := firstId + int64(i)
+				set_value_to_ifc(get_reflect_value_from_pos(row, pos), id)
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	// cache
+	if !opt.Ignore && opt.OnConflict == nil && q.tableEnableCache && tableStruct.EnableCache {
+		mp, ok := q.tableData[q.table]
+		if !ok {
+			q.Panic("q.tableData[q.table]: key %v does not exists.", q.table)
+		}
+		for _, row := range rows {
+			if tableStruct.reloadAfterWrite {
+				q.reload_row(tableStruct, row)
+			}
+			// 与 Insert() 相同，[]*T 缓存调用者的指针，[]T 缓存一份拷贝
+			row2 := row
+			if !elemIsPtr {
+				row2 = reflect.New(row.Elem().Type())
+				row2.Elem().Set(row.Elem())
+			}
+			mp.Store(get_pk_keys(tableStruct, row.Elem()), row2.Interface())
+		}
+	}
+	return ids, nil
+}
+
+func str_arr_equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 按照主键批量更新，更新 list 中每一行除了主键、自增列以外的列，不先查询主键，更新后按照主键重新读取到缓存：
+//
+//	n, err := db.Table("user").UpdateBatch(list)
+//
+// MySQL 为 UPDATE ... SET col=CASE WHEN pk=? THEN ? ... END WHERE pk IN (...)，SQLite 为每行一条 UPDATE，
+// 都在一个事务中执行；Cassandra 为 BEGIN UNLOGGED BATCH，不返回受影响的行数。
+func (q *Query) UpdateBatch(list interface{}, opts ...BatchOpts) (affectedRows int64, err error) {
+	if q.readOnly {
+		return
+	}
+	defer dbxErrorDefer(&err, q)
+
+	var opt BatchOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	listValue := reflect.ValueOf(list)
+	if listValue.Kind() == reflect.Ptr {
+		listValue = listValue.Elem()
+	}
+	if listValue.Kind() != reflect.Slice {
+		q.Panic("UpdateBatch(): must pass a slice: %T", list)
+	}
+	elemType := listValue.Type().Elem()
+	elemIsPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if elemIsPtr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		q.Panic("UpdateBatch(): slice element must be a struct or a struct pointer: %v", elemType)
+	}
+	tableStruct := q.getTableStruct(reflect.New(structType).Type())
+	if len(tableStruct.PrimaryKey) == 0 {
+		q.Panic("UpdateBatch(): table %v has no primary key", q.table)
+	}
+	if listValue.Len() == 0 {
+		return
+	}
+
+	// 每行的列相同：主键、自增列、只读列以外的所有列
+	rows := make([]reflect.Value, listValue.Len())
+	sets := make([][]interface{}, len(rows))
+	pks := make([][]interface{}, len(rows))
+	var colNames []string
+	for i := range rows {
+		row := listValue.Index(i)
+		if elemIsPtr {
+			if row.IsNil() {
+				q.Panic("UpdateBatch(): nil row at index %v", i)
+			}
+		} else {
+			row = row.Addr()
+		}
+		rows[i] = row
+		colNames, sets[i], _, _ = struct_value_to_args(tableStruct, row.Elem(), true, true, q.isCQL, false)
+		pks[i] = get_pk_args(tableStruct, row.Elem(), q.isCQL)
+	}
+	if len(colNames) == 0 {
+		q.Panic("UpdateBatch(): table %v has no column to update", q.table)
+	}
+
+	updateFields := arr_to_sql_add(colNames, "=?", ",", q.isCQL)
+	where := arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
+	sql1 := fmt.Sprintf("UPDATE %v SET %v WHERE %v", q.tableSQL(), updateFields, where)
+	size, maxArgs := q.batch_limits(opt)
+
+	switch q.DriverType {
+	case DRIVER_CQL:
+		for start := 0; start < len(rows); start += size {
+			end := start + size
+			if end > len(rows) {
+				end = len(rows)
+			}
+			var args []interface{}
+			for i := start; i < end; i++ {
+				args = append(append(args, sets[i]...), pks[i]...)
+			}
+			sql2 := "BEGIN UNLOGGED BATCH " + strings.Repeat(sql1+"; ", end-start) + "APPLY BATCH"
+			if _, err = q.Exec(sql2, args...); err != nil {
+				return
+			}
+		}
+	default:
+		var tx *sql.Tx
+		if tx, err = q.DB.DB.Begin(); err != nil {
+			return
+		}
+		if q.DriverType == DRIVER_MYSQL {
+			affectedRows, err = q.update_batch_case(tx, tableStruct, colNames, sets, pks, size, maxArgs)
+		} else {
+			affectedRows, err = q.update_batch_rows(tx, sql1, sets, pks)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			affectedRows = 0
+			return
+		}
+	}
+
+	// cache：MySQL / SQLite 按照主键重新读取，数据库中没有的行不缓存；Cassandra 的 UPDATE 不存在时插入，与 Update() 相同
+	if q.tableEnableCache && tableStruct.EnableCache {
+		mp, ok := q.tableData[q.table]
+		if !ok {
+			q.Panic("q.tableData[q.table]: key %v does not exists.", q.table)
+		}
+		if !q.isCQL {
+			q.reload_cache_by_pks(tableStruct, mp, pks, size, maxArgs)
+			return
+		}
+		for _, row := range rows {
+			if tableStruct.reloadAfterWrite {
+				q.reload_row(tableStruct, row)
+			}
+			row2 := row
+			if !elemIsPtr {
+				row2 = reflect.New(row.Elem().Type())
+				row2.Elem().Set(row.Elem())
+			}
+			mp.Store(get_pk_keys(tableStruct, row.Elem()), row2.Interface())
+		}
+	}
+	return
+}
+
+// 按照主键分批从数据库读取，替换缓存中的行
+func (q *Query) reload_cache_by_pks(tableStruct *TableStruct, mp *syncmap.Map, pks [][]interface{}, size int, maxArgs int) {
+	fields := arr_to_sql_add(tableStruct.ColFieldMap.colArr, "", ",", q.isCQL)
+	if n := maxArgs / len(tableStruct.PrimaryKey); n < size {
+		size = n
+	}
+	for start := 0; start < len(pks); start += size {
+		end := start + size
+		if end > len(pks) {
+			end = len(pks)
+		}
+		var args []interface{}
+		for i := start; i < end; i++ {
+			args = append(args, pks[i]...)
+		}
+		sql1 := fmt.Sprintf("SELECT %v FROM %v WHERE %v", fields, q.tableSQL(), pk_in_sql(tableStruct.PrimaryKey, end-start, q.isCQL))
+		list, err := q.get_list_by_sql(sql1, args...)
+		if err != nil && err != ErrNoRows {
+			panic(dbxErrorNew("reload cache failed: %v", err))
+		}
+		if !list.IsValid() {
+			continue
+		}
+		for i := 0; i < list.Elem().Len(); i++ {
+			row := list.Elem().Index(i)
+			mp.Store(get_pk_keys(tableStruct, row.Elem()), row.Interface())
+		}
+	}
+}
+
+// 每行一条 UPDATE
+func (q *Query) update_batch_rows(tx *sql.Tx, sql1 string, sets [][]interface{}, pks [][]interface{}) (affectedRows int64, err error) {
+	var stmt *sql.Stmt
+	if stmt, err = tx.Prepare(sql1); err != nil {
+		q.ErrorSQL(err.Error(), sql1)
+		return
+	}
+	defer stmt.Close()
+	for i := range sets {
+		args := append(append([]interface{}{}, sets[i]...), pks[i]...)
+		var result sql.Result
+		result, err = stmt.Exec(args...)
+		q.LogSQL(sql1, args...)
+		if err != nil {
+			q.ErrorSQL(err.Error(), sql1, args...)
+			return
+		}
+		var n int64
+		if n, err = result.RowsAffected(); err != nil {
+			return
+		}
+		affectedRows += n
+	}
+	return
+}
+
+// MySQL：SET col=CASE WHEN pk=? THEN ? ... ELSE col END，按照 Size、MaxArgs 分成多条
+func (q *Query) update_batch_case(tx *sql.Tx, tableStruct *TableStruct, colNames []string, sets [][]interface{}, pks [][]interface{}, size int, maxArgs int) (affectedRows int64, err error) {
+	pkN := len(tableStruct.PrimaryKey)
+	perRow := len(colNames)*(pkN+1) + pkN
+	if n := maxArgs / perRow; n < size {
+		size = n
+	}
+	if size < 1 {
+		q.Panic("UpdateBatch(): %v args per row exceeds MaxArgs %v", perRow, maxArgs)
+	}
+	when := arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", false)
+	for start := 0; start < len(sets); start += size {
+		end := start + size
+		if end > len(sets) {
+			end = len(sets)
+		}
+		var args []interface{}
+		arr := make([]string, len(colNames))
+		for k, colName := range colNames {
+			col := quote_ident(colName, false)
+			arr[k] = col + "=CASE" + strings.Repeat(" WHEN "+when+" THEN ?", end-start) + " ELSE " + col + " END"
+			for i := start; i < end; i++ {
+				args = append(append(args, pks[i]...), sets[i][k])
+			}
+		}
+		for i := start; i < end; i++ {
+			args = append(args, pks[i]...)
+		}
+		sql1 := fmt.Sprintf("UPDATE %v SET %v WHERE %v", q.tableSQL(), strings.Join(arr, ","), pk_in_sql(tableStruct.PrimaryKey, end-start, false))
+		var result sql.Result
+		result, err = tx.Exec(sql1, args...)
+		q.LogSQL(sql1, args...)
+		if err != nil {
+			q.ErrorSQL(err.Error(), sql1, args...)
+			return
+		}
+		var n int64
+		if n, err = result.RowsAffected(); err != nil {
+			return
+		}
+		affectedRows += n
+	}
+	return
+}
+
+// 按照主键批量删除，不先查询主键，直接从缓存中删除：
+//
+//	n, err := db.Table("user").DeleteByPKs(1, 2, 3)
+//	n, err := db.Table("user").DeleteByPKs([]int64{1, 2, 3})
+//	n, err := db.Table("user_group").DeleteByPKs([]interface{}{1, 2}, []interface{}{1, 3}) // 联合主键
+//
+// 只按照主键删除，不能与 Where() 等条件同时使用；Cassandra 为 BEGIN UNLOGGED BATCH，不返回受影响的行数。
+func (q *Query) DeleteByPKs(keys ...interface{}) (n int64, err error) {
+	if q.readOnly {
+		return
+	}
+	defer dbxErrorDefer(&err, q)
+
+	tableStruct := q.getTableStruct()
+	if tableStruct == nil {
+		q.Panic("DeleteByPKs(): table %v is not bound", q.table)
+	}
+	pkN := len(tableStruct.PrimaryKey)
+	if pkN == 0 {
+		q.Panic("DeleteByPKs(): table %v has no primary key", q.table)
+	}
+	if q.where != "" || len(q.whereM) > 0 || len(q.whereJSON) > 0 || len(q.primaryArgs) > 0 {
+		q.Panic("DeleteByPKs(): can not be used with other conditions")
+	}
+
+	// 单列主键时可以传一个 slice
+	if len(keys) == 1 && pkN == 1 {
+		if v := reflect.ValueOf(keys[0]); v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			keys = make([]interface{}, v.Len())
+			for i := range keys {
+				keys[i] = v.Index(i).Interface()
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	pks := make([][]interface{}, len(keys))
+	for i, key := range keys {
+		if pkN == 1 {
+			pks[i] = []interface{}{key}
+			continue
+		}
+		pk, ok := key.([]interface{})
+		if !ok || len(pk) != pkN {
+			q.Panic("DeleteByPKs(): composite primary key must be []interface{} of %v values: %v", pkN, key)
+		}
+		pks[i] = pk
+	}
+
+	size, maxArgs := q.batch_limits(BatchOpts{})
+	if n := maxArgs / pkN; n < size {
+		size = n
+	}
+	where := arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
+	for start := 0; start < len(pks); start += size {
+		end := start + size
+		if end > len(pks) {
+			end = len(pks)
+		}
+		var args []interface{}
+		for i := start; i < end; i++ {
+			args = append(args, pk_args_to_db(tableStruct, pks[i], q.isCQL)...)
+		}
+		var sql1 string
+		if q.isCQL {
+			sql1 = "BEGIN UNLOGGED BATCH " + strings.Repeat(fmt.Sprintf("DELETE FROM %v WHERE %v; ", q.tableSQL(), where), end-start) + "APPLY BATCH"
+		} else {
+			sql1 = fmt.Sprintf("DELETE FROM %v WHERE %v", q.tableSQL(), pk_in_sql(tableStruct.PrimaryKey, end-start, false))
+		}
+		var n2 int64
+		if n2, err = q.Exec(sql1, args...); err != nil {
+			return
+		}
+		n += n2
+
+		// cache
+		if q.tableEnableCache && tableStruct.EnableCache {
+			if mp, ok := q.tableData[q.table]; ok {
+				for i := start; i < end; i++ {
+					mp.Delete(get_pk_key_by_args(tableStruct, pks[i]))
+				}
+			}
+		}
+	}
+	return
+}
+
+// n 行的主键条件：pk IN (?,?)，联合主键为 (a=? AND b=?) OR (a=? AND b=?)
+func pk_in_sql(pkColNames []string, n int, isCQL bool) string {
+	if len(pkColNames) == 1 {
+		return arr_to_sql_add(pkColNames, "", "", isCQL) + " IN (" + strings.TrimRight(strings.Repeat("?,", n), ",") + ")"
+	}
+	one := "(" + arr_to_sql_add(pkColNames, "=?", " AND ", isCQL) + ")"
+	return strings.TrimSuffix(strings.Repeat(one+" OR ", n), " OR ")
+}
+
+// 主键的值，按照 PrimaryKey 的顺序
+func get_pk_args(tableStruct *TableStruct, row reflect.Value, isCQL bool) []interface{} {
+	args := make([]interface{}, len(tableStruct.PrimaryKey))
+	for i, colName := range tableStruct.PrimaryKey {
+		col := tableStruct.ColFieldMap.GetByColName(colName)
+		v := value_to_arg(get_value_from_pos(row, col.FieldPos), isCQL)
+		if tm, ok := v.(time.Time); ok {
+			v = tableStruct.time_policy(col).to_db(tm, isCQL)
+		}
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/xiuno/dbx"
+	"github.com/xiuno/dbx/gen"
+)
+
+const usage = `dbx: tools for github.com/xiuno/dbx
+
+Usage:
+	dbx gen --driver mysql --dsn "root@tcp(localhost)/test?parseTime=true" --out ./models
+	dbx gen --driver sqlite3 --dsn ./db1.db --out ./models --tables user,group
+	dbx gen --driver cql --dsn "root@tcp(192.168.0.129:9042)/test" --out ./models
+	dbx gen --driver mysql --dsn "..." --out ./models --mapper
+	dbx mapper --file user.go --type User
+
+	mapper: generate ScanRow/Args (dbx.Mapper) into <file>_dbx.go, avoid reflection.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "gen":
+		err = cmdGen(os.Args[2:])
+	case "mapper":
+		err = cmdMapper(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %v\n\n%v", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dbx %v: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func cmdGen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	driver := fs.String("driver", "mysql", "mysql / sqlite3 / cql")
+	dsn := fs.String("dsn", "", "data source name, cql supports multi hosts separated by comma")
+	out := fs.String("out", "./models", "output directory")
+	pkg := fs.String("pkg", "", "package name, default is the name of output directory")
+	tables := fs.String("tables", "", "tables separated by comma, default is all tables")
+	mapper := fs.Bool("mapper", false, "generate dbx.Mapper implementation into <table>_dbx.go")
+	fs.Parse(args)
+
+	if *dsn == "" {
+		return fmt.Errorf("--dsn is required")
+	}
+	dsns := []string{*dsn}
+	if *driver == "cql" {
+		dsns = strings.Split(*dsn, ",")
+	}
+	db, err := dbx.Open(*driver, dsns...)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	opts := gen.Options{Out: *out, Package: *pkg, Mapper: *mapper}
+	opts.Tables = splitList(*tables)
+	files, err := gen.Generate(db, opts)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Println(file)
+	}
+	return nil
+}
+
+func cmdMapper(args []string) error {
+	fs := flag.NewFlagSet("mapper", flag.ExitOnError)
+	file := fs.String("file", os.Getenv("GOFILE"), "go source file, default is $GOFILE (go:generate)")
+	types := fs.String("type", "", "structs separated by comma, default is all structs with db tag")
+	fs.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("--file is required")
+	}
+	pkg, tables, err := gen.ParseFile(*file, splitList(*types))
+	if err != nil {
+		return err
+	}
+	src, err := gen.RenderMapper(pkg, tables)
+	if err != nil {
+		return err
+	}
+	out := strings.TrimSuffix(*file, ".go") + "_dbx.go"
+	if err = ioutil.WriteFile(out, src, 0644); err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,201 @@
+package dbx
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gocql/gocql"
+)
+
+// 自定义类型的转换函数，v 的类型为 from，返回 to 类型的值
+type ConverterFunc func(v interface{}) (interface{}, error)
+
+type converter struct {
+	to reflect.Type
+	fn ConverterFunc
+}
+
+var (
+	convertersMu    sync.RWMutex
+	converters      = map[reflect.Type][]converter{} // 读：数据库返回的类型 -> 字段类型
+	writeConverters = map[reflect.Type]converter{}   // 写：字段类型 -> 参数的类型
+	convertersN     int32                            // 没有注册时跳过查找
+)
+
+// 注册读取时的转换：数据库返回的 from 类型的值 -> to 类型的字段，写入需要用 RegisterWriteConverter() 注册：
+//
+//	// 读：数据库中的 string -> decimal.Decimal
+//	dbx.RegisterConverter(reflect.TypeOf(""), reflect.TypeOf(decimal.Decimal{}), func(v interface{}) (interface{}, error) {
+//		return decimal.NewFromString(v.(string))
+//	})
+//	// 写：decimal.Decimal -> string
+//	dbx.RegisterWriteConverter(reflect.TypeOf(decimal.Decimal{}), reflect.TypeOf(""), func(v interface{}) (interface{}, error) {
+//		return v.(decimal.Decimal).String(), nil
+//	})
+//
+// 读取时按照 (数据库返回的类型, 字段类型) 查找，[]byte 同时会尝试 string。
+// 注册的转换优先于 sql.Scanner / driver.Valuer。
+func RegisterConverter(from, to reflect.Type, fn ConverterFunc) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	list := converters[from]
+	for i, c := range list {
+		if c.to == to {
+			list[i].fn = fn
+			return
+		}
+	}
+	converters[from] = append(list, converter{to: to, fn: fn})
+	atomic.AddInt32(&convertersN, 1)
+}
+
+// 注册写入时的转换：from 类型的字段的值 -> to 类型的 SQL / CQL 参数，每个类型只有一个，后注册的覆盖先注册的
+func RegisterWriteConverter(from, to reflect.Type, fn ConverterFunc) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	writeConverters[from] = converter{to: to, fn: fn}
+	atomic.AddInt32(&convertersN, 1)
+}
+
+// 读：数据库返回的 from 类型 -> to 类型的字段
+func find_converter(from, to reflect.Type) ConverterFunc {
+	if atomic.LoadInt32(&convertersN) == 0 {
+		return nil
+	}
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	for _, c := range converters[from] {
+		if c.to == to {
+			return c.fn
+		}
+	}
+	return nil
+}
+
+// 写：from 类型的字段
+func find_write_converter(from reflect.Type) ConverterFunc {
+	if atomic.LoadInt32(&convertersN) == 0 {
+		return nil
+	}
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	return writeConverters[from].fn
+}
+
+// 写：from 类型的字段转换后的参数类型，没有注册返回 nil
+func write_converter_type(from reflect.Type) reflect.Type {
+	if atomic.LoadInt32(&convertersN) == 0 {
+		return nil
+	}
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	return writeConverters[from].to
+}
+
+// 是否有读取时转换到 to 类型
+func has_converter_to(to reflect.Type) bool {
+	if atomic.LoadInt32(&convertersN) == 0 {
+		return false
+	}
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	for _, list := range converters {
+		for _, c := range list {
+			if c.to == to {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 字段类型 t 是否注册了读或者写的转换
+func has_converter(t reflect.Type) bool {
+	return find_write_converter(t) != nil || has_converter_to(t)
+}
+
+// 读：按照注册的转换赋值，没有注册返回 false
+func convert_by_registry(dv reflect.Value, src interface{}) bool {
+	st := reflect.TypeOf(src)
+	fn := find_converter(st, dv.Type())
+	if fn == nil {
+		if b, ok := src.([]byte); ok {
+			src = uint8_to_string(b)
+			fn = find_converter(reflect.TypeOf(""), dv.Type())
+		}
+	}
+	if fn == nil {
+		return false
+	}
+	v, err := fn(src)
+	if err != nil {
+		panic(dbxErrorNew("convert failed: %v -> %v, error: %v", st, dv.Type(), err.Error()))
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		dv.Set(reflect.Zero(dv.Type()))
+	} else if rv.Type() != dv.Type() {
+		if !rv.Type().ConvertibleTo(dv.Type()) {
+			panic(dbxErrorNew("convert failed: converter returns %v, expect %v", rv.Type(), dv.Type()))
+		}
+		dv.Set(rv.Convert(dv.Type()))
+	} else {
+		dv.Set(rv)
+	}
+	return true
+}
+
+// 写：字段的值转换为 SQL / CQL 的参数，gocql.Marshaler(CQL) > 注册的转换 > driver.Valuer
+func value_to_arg(vi interface{}, isCQL bool) interface{} {
+	if vi == nil {
+		return nil
+	}
+	if isCQL {
+		if _, ok := vi.(gocql.Marshaler); ok {
+			return vi
+		}
+	}
+	if fn := find_write_converter(reflect.TypeOf(vi)); fn != nil {
+		v, err := fn(vi)
+		if err != nil {
+			panic(dbxErrorNew("convert failed: %T, error: %v", vi, err.Error()))
+		}
+		return v
+	}
+	if valuer, ok := vi.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			panic(dbxErrorNew("driver.Valuer %T: %v", vi, err.Error()))
+		}
+		return v
+	}
+	return vi
+}
+
+// CQL Scan 的目标：实现了 sql.Scanner 或者注册了转换的字段，先 Scan 到列的原生类型（**T，NULL 为 nil），
+// 再由 set_col_value() 转换
+func cql_scan_dest(col *Col, info gocql.ColumnInfo) interface{} {
+	t := col.FieldStruct.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	pt := reflect.PtrTo(t)
+	native := false
+	if !col.JSON && !pt.Implements(cqlUnmarshalerType) {
+		native = pt.Implements(scannerType) || has_converter_to(t)
+	}
+	if native && info.TypeInfo != nil {
+		return reflect.New(reflect.TypeOf(info.TypeInfo.New())).Interface()
+	}
+	return reflect.New(col.scanType()).Interface()
+}
+
+var (
+	scannerType        = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType         = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	cqlMarshalerType   = reflect.TypeOf((*gocql.Marshaler)(nil)).Elem()
+	cqlUnmarshalerType = reflect.TypeOf((*gocql.Unmarshaler)(nil)).Elem()
+)
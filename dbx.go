@@ -81,22 +81,33 @@ func Now() string {
 // 错误处理，仅用于 dbx 类，对外部透明，对外部仅返回标准的 error 和 panic()
 type dbxError struct {
 	data string
+	err  error // 可以通过 errors.Is() / errors.As() 判断的错误，例如 *IdentifierError
 }
 
 func (e dbxError) Error() string {
 	return e.data
 }
+func (e dbxError) Unwrap() error {
+	return e.err
+}
 func dbxErrorNew(s string, args ...interface{}) *dbxError {
 	e := &dbxError{data: fmt.Sprintf(s, args...)}
 	return e
 }
+func dbxErrorWrap(err error) *dbxError {
+	return &dbxError{data: err.Error(), err: err}
+}
 func dbxErrorType(err interface{}) bool {
 	_, ok := err.(*dbxError)
 	return ok
 }
 func dbxErrorDefer(err *error, db *Query) {
 	if err1 := recover(); err1 != nil {
-		*err = fmt.Errorf("dbx panic(): %v", err1)
+		if e, ok := err1.(*dbxError); ok && e.err != nil {
+			*err = fmt.Errorf("dbx panic(): %w", e.err)
+		} else {
+			*err = fmt.Errorf("dbx panic(): %v", err1)
+		}
 		//return
 		if !dbxErrorType(err1) {
 			db.ErrorLog((*err).Error())
@@ -133,6 +144,27 @@ type Col struct {
 	FieldName   string // 结构体中的名字：Id
 	FieldPos    []int  // 在结构体中的位置，支持嵌套 [1,0,-1,-1,-1]
 	FieldStruct reflect.StructField
+
+	// db tag 中的选项: db:"uid,pk,autoincr"
+	PrimaryKey    bool
+	AutoIncrement bool
+	ReadOnly      bool   // 从不写入
+	OmitEmpty     bool   // Insert 时零值不写入
+	JSON          bool   // 以 JSON 字符串存储
+	Default       string // Insert 时零值使用的默认值
+	HasDefault    bool
+
+	timePolicy *colTimePolicy // db:"created,time=utc|ms"
+
+	Relation *Relation // dbx:"hasmany:post,fk=uid"，不对应列
+}
+
+// Scan 时使用的类型，json 列从数据库读出的是字符串
+func (col *Col) scanType() reflect.Type {
+	if col.JSON {
+		return reflect.TypeOf("")
+	}
+	return col.FieldStruct.Type
 }
 
 type ColFieldMap struct {
@@ -194,6 +226,21 @@ type TableStruct struct {
 	AutoIncrement string
 	Type          reflect.Type
 	EnableCache   bool
+	IsMapper      bool // Type 实现了 Mapper，读写不再反射；之后注册了转换时由 is_mapper() 重新检查
+	Relations     map[string]*Relation // 字段名 => 关联，Preload() 使用
+
+	reloadAfterWrite bool  // 有 omitempty / readonly 列，写入后需要从数据库重新读取，保证缓存一致
+	mapperConverters int32 // 检查 IsMapper 时注册的转换的个数
+	mapperOff        int32 // 之后注册的转换使 Mapper 不能使用
+
+	partitionKey  []string // Cassandra 的分区键，token() 翻页使用
+	clusteringKey []string // Cassandra 的聚簇列
+
+	db *DB
+}
+
+func (t *TableStruct) nullToZero() bool {
+	return t.db != nil && t.db.NullToZero
 }
 
 // pointerType 必须为约定值 &struct
@@ -202,13 +249,50 @@ func NewTableStruct(db *DB, tableName string, pointerType reflect.Type) (*TableS
 		pointerType = reflect.New(pointerType).Type()
 	}
 	colFieldMap := NewColFieldMap()
-	struct_fields_range_do(colFieldMap, pointerType, []int{})
+	struct_fields_range_do(colFieldMap, pointerType, []int{}, db.NamingStrategy, "")
 
 	t := &TableStruct{}
 	t.ColFieldMap = colFieldMap
 	t.Type = pointerType
+	t.IsMapper = pointerType.Implements(mapperType)
 	t.PrimaryKey, t.AutoIncrement = get_table_info(db, tableName)
 	t.EnableCache = false
+	t.db = db
+	t.Relations = map[string]*Relation{}
+
+	// db tag 中的 pk / autoincr 优先于表结构的检测结果
+	pk := make([]string, 0)
+	for _, col := range colFieldMap.cols {
+		if col.Relation != nil {
+			col.Relation.Field = col
+			t.Relations[col.FieldName] = col.Relation
+		}
+		if col.ColName == "" {
+			continue
+		}
+		if col.PrimaryKey {
+			pk = append(pk, col.ColName)
+		}
+		if col.AutoIncrement {
+			t.AutoIncrement = col.ColName
+		}
+		if !mapper_col_ok(db, col) {
+			t.IsMapper = false
+		}
+		if col.OmitEmpty || col.ReadOnly {
+			t.reloadAfterWrite = true
+		}
+	}
+	if len(pk) > 0 {
+		t.PrimaryKey = pk
+	}
+	if db.DriverType == DRIVER_CQL {
+		t.partitionKey, t.clusteringKey = cql_get_keys(db, tableName)
+	}
+	if t.IsMapper {
+		t.IsMapper = mapper_covers(t)
+		t.mapperConverters = atomic_converters_n()
+	}
 
 	// 保存主键的位置
 	t.PrimaryKeyPos = make([][]int, 0)
@@ -230,9 +314,16 @@ type DB struct {
 	Stdout     io.Writer
 	Stderr     io.Writer
 
+	NamingStrategy NamingStrategy // 没有 db tag 的字段的列名，默认不映射
+	NullToZero     bool           // NULL 读入非指针、非 sql.Null* 的字段时赋零值，默认报错
+	TimePolicy     TimePolicy     // time.Time 的存储格式、时区和精度，可以被列的 db tag 覆盖
+
 	// todo: 按照行缓存数据，只缓存主键条件的查询
 	tableStruct      map[string]*TableStruct
 	tableData        map[string]*syncmap.Map
+	resultStruct     *syncmap.Map // reflect.Type => *TableStruct，JOIN、GROUP BY 等查询的结果
+	scopes           *syncmap.Map // name => func(*Query) *Query
+	nullableCols     *syncmap.Map // 表名 => map[列名]bool，Page() 检查排序的列
 	tableEnableCache bool
 
 	readOnly bool // 只读模式，禁止写，防止出错。
@@ -242,7 +333,14 @@ type DB struct {
 type Query struct {
 	*DB
 	table  string
-	fields []string // SELECT
+	alias  string
+	joins  []joinClause
+	fields []interface{} // SELECT：列名或者 Expression
+
+	selects    []interface{} // Select()：列名或者 Aggregate
+	groupBy    []string
+	having     string
+	havingArgs []interface{}
 
 	primaryKeyStr string
 	primaryArgs   []interface{} // 主键的值
@@ -250,6 +348,8 @@ type Query struct {
 	where     string
 	whereArgs []interface{}
 	whereM    M
+	whereJSON []jsonCond
+	preload   []string
 
 	orderBy M
 
@@ -315,6 +415,9 @@ func Open(driverName string, dataSourceNames ... string) (*DB, error) {
 			Stderr:           os.Stderr,
 			tableStruct:      make(map[string]*TableStruct),
 			tableData:        make(map[string]*syncmap.Map), // 第一级的 map 会在启动的时候初始化好，第二级的使用安全 map
+			resultStruct:     new(syncmap.Map),
+			scopes:           new(syncmap.Map),
+			nullableCols:     new(syncmap.Map),
 			tableEnableCache: false,
 			isCQL: false,
 		}, err
@@ -345,6 +448,9 @@ func Open(driverName string, dataSourceNames ... string) (*DB, error) {
 			Stderr:           os.Stderr,
 			tableStruct:      make(map[string]*TableStruct),
 			tableData:        make(map[string]*syncmap.Map), // 第一级的 map 会在启动的时候初始化好，第二级的使用安全 map
+			resultStruct:     new(syncmap.Map),
+			scopes:           new(syncmap.Map),
+			nullableCols:     new(syncmap.Map),
 			tableEnableCache: false,
 			isCQL: true,
 		}, err
@@ -462,7 +568,7 @@ func (db *DB) Table(name string) *Query {
 	q := &Query{
 		DB:          db,
 		table:       name,
-		fields:      []string{},
+		fields:      []interface{}{},
 		whereArgs:   []interface{}{},
 		primaryArgs: []interface{}{},
 		updateArgs:  []interface{}{},
@@ -508,8 +614,17 @@ func (q *Query) AllFromCache() *syncmap.Map {
 	}
 }
 
-func (q *Query) Fields(fields ...string) *Query {
-	q.fields = fields
+// 列名或者 Expression：Fields("gid", dbx.Raw("COUNT(*) AS n"))
+func (q *Query) Fields(fields ...interface{}) *Query {
+	q = q.Clone()
+	for _, f := range fields {
+		switch f.(type) {
+		case string, Expression:
+		default:
+			q.Panic("Fields(): expect column name or dbx.Expression, got %T", f)
+		}
+	}
+	q.fields = append([]interface{}(nil), fields...)
 	return q
 }
 
@@ -565,6 +680,7 @@ func (q *Query) Where(str string, args ...interface{}) *Query {
 	//args_time_format(args)
 	//str1, args1 := where_prepare(str, args...) // 支持数组参数，自动展开，方便 id IN(?) 语法
 	str1, args1 := str, args
+	q = q.Clone()
 	if q.where == "" {
 		q.where = str1
 	} else {
@@ -591,6 +707,7 @@ func (q *Query) And(str string, args ...interface{}) *Query {
 }
 
 func (q *Query) Or(str string, args ...interface{}) *Query {
+	q = q.Clone()
 	if q.where == "" {
 		q.where = str
 	} else {
@@ -601,6 +718,7 @@ func (q *Query) Or(str string, args ...interface{}) *Query {
 }
 
 func (q *Query) WhereM(m M) *Query {
+	q = q.Clone()
 	q.whereM = append(q.whereM, m...)
 	/*
 		l := len(m)
@@ -617,6 +735,7 @@ func (q *Query) WhereM(m M) *Query {
 }
 
 func (q *Query) WherePK(args ...interface{}) *Query {
+	q = q.Clone()
 	q.primaryArgs = args
 	str := get_key_str_by_args(args...)
 	q.primaryKeyStr = str
@@ -637,8 +756,19 @@ func (q *Query) whereToSQL(tableStruct *TableStruct) (where string, args []inter
 
 	// 主键优先级最高，独占
 	if len(q.primaryArgs) > 0 {
-		where = " WHERE " + arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
-		args = q.primaryArgs
+		pk := tableStruct.PrimaryKey
+		if len(q.joins) > 0 {
+			// JOIN 时主键属于主表
+			pk = make([]string, len(tableStruct.PrimaryKey))
+			for i, colName := range tableStruct.PrimaryKey {
+				pk[i] = q.table + "." + colName
+				if q.alias != "" {
+					pk[i] = q.alias + "." + colName
+				}
+			}
+		}
+		where = " WHERE " + arr_to_sql_add(pk, "=?", " AND ", q.isCQL)
+		args = pk_args_to_db(tableStruct, q.primaryArgs, q.isCQL)
 		return
 	}
 
@@ -655,11 +785,19 @@ func (q *Query) whereToSQL(tableStruct *TableStruct) (where string, args []inter
 
 func (q *Query) whereToSQLDo() (where string, args []interface{}) {
 	// 合并所有的 where + whereM 条件
-	where = q.where
-	args = q.whereArgs
+	where, args = q.expand_args(q.where, q.whereArgs)
+	args = append([]interface{}{}, args...) // 不修改 q.whereArgs，q 可能被多个 goroutine 共享
 	if len(q.whereM) > 0 {
-		colNames, args2 := q.whereM.toKeysValues()
-		whereAdd := arr_to_sql_add(colNames, "=?", " AND ", q.isCQL)
+		whereAdd, args2 := q.whereMToSQL()
+		if where == "" {
+			where = whereAdd
+		} else {
+			where += " AND " + whereAdd
+		}
+		args = append(args, args2...)
+	}
+	if len(q.whereJSON) > 0 {
+		whereAdd, args2 := q.whereJSONToSQL()
 		if where == "" {
 			where = whereAdd
 		} else {
@@ -674,18 +812,6 @@ func (q *Query) whereToSQLDo() (where string, args []interface{}) {
 	return
 }
 
-func (q *Query) Sort(colName string, order int) *Query {
-	q.orderBy = append(q.orderBy, Map{colName, order})
-	return q
-}
-
-func (q *Query) SortM(m M) *Query {
-	for _, v := range m {
-		q.orderBy = append(q.orderBy, Map{v.Key, v.Value})
-	}
-	return q
-}
-
 /*
 	Limit(10)
 	Limit(0, 10) // Cassandra 不支持
@@ -698,44 +824,50 @@ func (q *Query) Limit(limitStart int64, limitEnds ...int64) *Query {
 	if len(limitEnds) > 0 {
 		limitEnd = limitEnds[0]
 	}
+	q = q.Clone()
 	q.limitStart = limitStart
 	q.limitEnd = limitEnd
 	return q
 }
 
-func (q *Query) orderByToSQL() string {
-	sqlAdd := ""
-	for _, m := range q.orderBy {
-		k := m.Key
-		v, ok := m.Value.(int)
-		if !ok {
-			sqlAdd += fmt.Sprintf("%v %v AND", k, "ASC")
-			continue
-		}
-		if v == 1 {
-			sqlAdd += fmt.Sprintf("%v %v AND", k, "ASC")
-		} else if v == -1 {
-			sqlAdd += fmt.Sprintf("%v %v AND", k, "DESC")
-		}
-	}
-	return strings.TrimRight(sqlAdd, " AND")
-}
-
 // 将当前条件转化为 SQL 语句
 func (q *Query) toSQL(tableStruct *TableStruct, action int, rvalues ...reflect.Value) (sql1 string, args []interface{}) {
 	fields := "*"
 	where := ""
 	orderBy := ""
 	limit := ""
+	var fieldArgs []interface{}
 	if len(q.fields) > 0 {
-		fields = strings.Join(q.fields, ",")
+		fields, fieldArgs = q.fieldsToSQL(tableStruct)
+	} else if len(q.selects) > 0 {
+		fields = q.selectsToSQL()
+	} else if len(q.joins) > 0 {
+		fields = join_fields_sql(tableStruct)
 	}
 
 	var allowFiltering string
 	where, args, allowFiltering = q.whereToSQL(tableStruct)
 
+	// SELECT、JOIN ... ON 中的参数在 WHERE 之前
+	from, fromArgs := q.fromToSQL()
+	if len(fromArgs) > 0 {
+		args = append(fromArgs, args...)
+	}
+	if len(fieldArgs) > 0 && (action == ACTION_SELECT_ONE || action == ACTION_SELECT_ALL) {
+		args = append(fieldArgs, args...)
+	}
+	groupBy, havingArgs := q.groupByToSQL()
+	if len(havingArgs) > 0 {
+		args = append(args, havingArgs...)
+	}
+
 	if len(q.orderBy) > 0 {
-		orderBy = " ORDER BY " + q.orderByToSQL()
+		var orderArgs []interface{}
+		orderBy, orderArgs = q.orderByToSQL(tableStruct)
+		orderBy = " ORDER BY " + orderBy
+		if action == ACTION_SELECT_ONE || action == ACTION_SELECT_ALL {
+			args = append(args, orderArgs...)
+		}
 	}
 	if q.limitStart != 0 || q.limitEnd != 0 {
 		if q.limitEnd == 0 {
@@ -747,66 +879,80 @@ func (q *Query) toSQL(tableStruct *TableStruct, action int, rvalues ...reflect.V
 	switch action {
 	case ACTION_SELECT_ONE:
 		limit = " LIMIT 1"
-		sql1 = fmt.Sprintf("SELECT %v FROM %v%v%v%v%v", fields, q.table, where, orderBy, limit, allowFiltering)
+		sql1 = fmt.Sprintf("SELECT %v FROM %v%v%v%v%v%v", fields, from, where, groupBy, orderBy, limit, allowFiltering)
 	case ACTION_SELECT_ALL:
-		sql1 = fmt.Sprintf("SELECT %v FROM %v%v%v%v%v", fields, q.table, where, orderBy, limit, allowFiltering)
+		sql1 = fmt.Sprintf("SELECT %v FROM %v%v%v%v%v%v", fields, from, where, groupBy, orderBy, limit, allowFiltering)
 	case ACTION_UPDATE:
 		if q.DriverType == DRIVER_MYSQL {
 			limit = " LIMIT 1"
 		}
 
-		var updateSets []interface{}
-		updateSets, pkArgs, _ := struct_value_to_args(tableStruct, rvalues[0], true, true, q.isCQL)
-
-		// todo: 去掉主键的更新
-		colNames := array_sub(tableStruct.ColFieldMap.colArr, tableStruct.PrimaryKey)
+		// 去掉主键和只读列的更新
+		colNames, updateSets, pkArgs, _ := struct_value_to_args(tableStruct, rvalues[0], true, true, q.isCQL, false)
 		updateFields := arr_to_sql_add(colNames, "=?", ",", q.isCQL)
 		if where == "" {
 			where = " WHERE " + arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
 			args = append(args, pkArgs...)
 		}
-		sql1 = fmt.Sprintf("UPDATE %v SET %v%v%v", q.table, updateFields, where, limit)
+		sql1 = fmt.Sprintf("UPDATE %v SET %v%v%v", q.tableSQL(), updateFields, where, limit)
 		args = append(updateSets, args...)
 	case ACTION_UPDATE_M:
 		if q.DriverType == DRIVER_SQLITE {
 			limit = ""
 		}
-		colNames := arr_to_sql_add_update(q.updateFields, q.updateOps, q.isCQL)
-		sql1 = fmt.Sprintf("UPDATE %v SET %v%v%v", q.table, colNames, where, limit) // UPDATE 不支持 ALLOW FILTERING
-		args = append(q.updateArgs, args...)
+		for _, colName := range q.updateFields {
+			check_ident("column", colName)
+		}
+		colNames := arr_to_sql_add_update(q.updateFields, q.updateOps, q.DriverType, tableStruct)
+		// json 列写入 JSON 字符串，q.updateArgs 保留原值用于更新缓存
+		updateArgs := make([]interface{}, 0, len(q.updateArgs)+len(args))
+		for i, v := range q.updateArgs {
+			col := tableStruct.ColFieldMap.GetByColName(q.updateFields[i])
+			switch {
+			case is_sql_value(v):
+				// Expression、子查询在下面展开为 SQL
+			case col != nil && col.JSON && q.updateOps[i] == "=":
+				v = json_marshal(v)
+			default:
+				v = value_to_arg(v, q.isCQL)
+			}
+			updateArgs = append(updateArgs, v)
+		}
+		// 每列一个 ?，Expression、子查询替换为 SQL
+		colNames, updateArgs = q.expand_args(colNames, updateArgs)
+		sql1 = fmt.Sprintf("UPDATE %v SET %v%v%v", q.tableSQL(), colNames, where, limit) // UPDATE 不支持 ALLOW FILTERING
+		args = append(updateArgs, args...)
 	case ACTION_DELETE:
 		if q.DriverType == DRIVER_SQLITE {
 			limit = ""
 		}
-		sql1 = fmt.Sprintf("DELETE FROM %v%v%v%v", q.table, where, limit, allowFiltering)
+		sql1 = fmt.Sprintf("DELETE FROM %v%v%v%v", q.tableSQL(), where, limit, allowFiltering)
 	case ACTION_INSERT:
-		uncludes := []string{tableStruct.AutoIncrement}
-		colNames := array_sub(tableStruct.ColFieldMap.colArr, uncludes)
+		var colNames []string
+		colNames, args, _, _ = struct_value_to_args(tableStruct, rvalues[0], true, false, q.isCQL, true)
 		fields := arr_to_sql_add(colNames, "", ",", q.isCQL)
 		values := strings.TrimRight(strings.Repeat("?,", len(colNames)), ",")
-		sql1 = fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", q.table, fields, values)
-		args, _, _ = struct_value_to_args(tableStruct, rvalues[0], true, false, q.isCQL)
+		sql1 = fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", q.tableSQL(), fields, values)
 	case ACTION_INSERT_IGNORE:
 		// copy from ACTION_INSERT
-		uncludes := []string{tableStruct.AutoIncrement}
-		colNames := array_sub(tableStruct.ColFieldMap.colArr, uncludes)
+		var colNames []string
+		colNames, args, _, _ = struct_value_to_args(tableStruct, rvalues[0], true, false, q.isCQL, true)
 		fields := arr_to_sql_add(colNames, "", ",", q.isCQL)
 		values := strings.TrimRight(strings.Repeat("?,", len(colNames)), ",")
 		if q.DriverType == DRIVER_MYSQL {
-			sql1 = fmt.Sprintf("INSERT IGNORE INTO %v (%v) VALUES (%v)", q.table, fields, values)
+			sql1 = fmt.Sprintf("INSERT IGNORE INTO %v (%v) VALUES (%v)", q.tableSQL(), fields, values)
 		} else if q.DriverType == DRIVER_SQLITE {
-			sql1 = fmt.Sprintf("INSERT OR IGNORE INTO %v (%v) VALUES (%v)", q.table, fields, values)
+			sql1 = fmt.Sprintf("INSERT OR IGNORE INTO %v (%v) VALUES (%v)", q.tableSQL(), fields, values)
 		}
-		args, _, _ = struct_value_to_args(tableStruct, rvalues[0], true, false, q.isCQL)
 		// copy end
 	case ACTION_REPLACE:
-		tableStruct := q.tableStruct[q.table]
-		fields := arr_to_sql_add(tableStruct.ColFieldMap.colArr, "", ",", q.isCQL)
-		values := strings.TrimRight(strings.Repeat("?,", len(tableStruct.ColFieldMap.colArr)), ",")
-		sql1 = fmt.Sprintf("REPLACE INTO %v (%v) VALUES (%v)", q.table, fields, values)
-		args, _, _ = struct_value_to_args(tableStruct, rvalues[0], false, false, q.isCQL)
+		var colNames []string
+		colNames, args, _, _ = struct_value_to_args(tableStruct, rvalues[0], false, false, q.isCQL, true)
+		fields := arr_to_sql_add(colNames, "", ",", q.isCQL)
+		values := strings.TrimRight(strings.Repeat("?,", len(colNames)), ",")
+		sql1 = fmt.Sprintf("REPLACE INTO %v (%v) VALUES (%v)", q.tableSQL(), fields, values)
 	case ACTION_COUNT:
-		sql1 = fmt.Sprintf("SELECT COUNT(*) FROM %v%v%v", fields, q.table, where, allowFiltering)
+		sql1 = fmt.Sprintf("SELECT COUNT(*) FROM %v%v%v", q.tableSQL(), where, allowFiltering)
 	case ACTION_SUM:
 	}
 	return
@@ -829,9 +975,33 @@ func (q *Query) One(arrIfc interface{}) (err error) {
 		q.Panic(errStr)
 	}
 
+	// JOIN、GROUP BY 的结果按照 arrType 读取，开启缓存的表在内存中聚合
+	if q.is_projection() {
+		tableStruct := q.getResultTableStruct(arrType)
+		if columns, rows, ok := q.memory_aggregate(); ok {
+			if len(rows) == 0 {
+				return ErrNoRows
+			}
+			values_to_struct(tableStruct, columns, rows[0], arrValue)
+			return nil
+		}
+		sql1, args := q.toSQL(tableStruct, ACTION_SELECT_ONE)
+		err = q.get_row_by_sql(arrValue, tableStruct, sql1, args...)
+		return
+	}
+
 	// 如果没有 Bind() ，这里就会执行下去，从缓存里读表结构，不用每次都反射，提高效率
 	tableStruct := q.getTableStruct(arrType)
 
+	// 加载关联，在 dbxErrorDefer() 之前执行
+	if len(q.preload) > 0 {
+		defer func() {
+			if err == nil {
+				q.preload_rows(tableStruct, []reflect.Value{arrValue})
+			}
+		}()
+	}
+
 	// 判断是否开启了缓存
 	if q.tableEnableCache && tableStruct.EnableCache && len(q.primaryArgs) > 0 {
 		if len(q.primaryKeyStr) != 0 {
@@ -841,7 +1011,7 @@ func (q *Query) One(arrIfc interface{}) (err error) {
 				q.ErrorLog(errStr)
 				return errors.New(errStr)
 			}
-			ifc, ok := mp.Load(q.primaryKeyStr)
+			ifc, ok := mp.Load(get_pk_key_by_args(tableStruct, q.primaryArgs))
 			if ok {
 				arrValue.Elem().Set(reflect.ValueOf(ifc).Elem())
 				return nil
@@ -854,6 +1024,16 @@ func (q *Query) One(arrIfc interface{}) (err error) {
 		}
 	}
 
+	// JSON 条件在缓存中执行
+	if len(q.whereJSON) > 0 && tableStruct.Type == arrType && q.memory_enabled(tableStruct) {
+		rows := q.memory_rows(tableStruct)
+		if len(rows) == 0 {
+			return ErrNoRows
+		}
+		arrValue.Elem().Set(rows[0].Elem())
+		return nil
+	}
+
 	sql1, args := q.toSQL(tableStruct, ACTION_SELECT_ONE)
 	err = q.get_row_by_sql(arrValue, tableStruct, sql1, args...)
 	return
@@ -924,8 +1104,83 @@ func (q *Query) All(arrListIfc interface{}) (err error) {
 	arrIsPtr := (arrType.Kind() == reflect.Ptr)
 
 	arrListValue := reflect.ValueOf(arrListIfc).Elem()
-	// 如果没有 Bind() ，这里就会执行下去，从缓存里读表结构，不用每次都反射，提高效率
-	tableStruct := q.getTableStruct(arrType)
+
+	// []map[string]interface{}
+	if arrType == mapType {
+		var list []map[string]interface{}
+		list, err = q.all_maps()
+		arrListValue.Set(reflect.ValueOf(list))
+		if err == nil && len(list) == 0 {
+			err = ErrNoRows
+		}
+		return
+	}
+
+	// 如果没有 Bind() ，这里就会执行下去，从缓存里读表结构，不用每次都反射，提高效率；JOIN、GROUP BY 的结果单独缓存
+	var tableStruct *TableStruct
+	if q.is_projection() {
+		tableStruct = q.getResultTableStruct(arrType)
+	} else {
+		tableStruct = q.getTableStruct(arrType)
+	}
+
+	// 加载关联，在 dbxErrorDefer() 之前执行
+	if len(q.preload) > 0 {
+		defer func() {
+			if err != nil {
+				return
+			}
+			rows := make([]reflect.Value, arrListValue.Len())
+			for i := range rows {
+				rows[i] = arrListValue.Index(i)
+				if !arrIsPtr {
+					rows[i] = rows[i].Addr()
+				}
+			}
+			q.preload_rows(tableStruct, rows)
+		}()
+	}
+
+	// JSON 条件在缓存中执行，返回缓存的拷贝
+	structType := arrType
+	if arrIsPtr {
+		structType = arrType.Elem()
+	}
+	if columns, rows, ok := q.memory_aggregate(); ok {
+		dest := reflect.MakeSlice(arrlist, 0, len(rows))
+		for _, values := range rows {
+			row := reflect.New(structType)
+			values_to_struct(tableStruct, columns, values, row)
+			if arrIsPtr {
+				dest = reflect.Append(dest, row)
+			} else {
+				dest = reflect.Append(dest, row.Elem())
+			}
+		}
+		arrListValue.Set(dest)
+		if len(rows) == 0 {
+			return ErrNoRows
+		}
+		return nil
+	}
+	if len(q.whereJSON) > 0 && !q.is_projection() && tableStruct.Type.Elem() == structType && q.memory_enabled(tableStruct) {
+		rows := q.memory_rows(tableStruct)
+		dest := reflect.MakeSlice(arrlist, 0, len(rows))
+		for _, row := range rows {
+			if arrIsPtr {
+				row2 := reflect.New(structType)
+				row2.Elem().Set(row.Elem())
+				dest = reflect.Append(dest, row2)
+			} else {
+				dest = reflect.Append(dest, row.Elem())
+			}
+		}
+		arrListValue.Set(dest)
+		if len(rows) == 0 {
+			return ErrNoRows
+		}
+		return nil
+	}
 
 	// 判断是否为 whereM
 	sql1, args := q.toSQL(tableStruct, ACTION_SELECT_ALL)
@@ -956,40 +1211,47 @@ func (q *Query) Count() (n int64, err error) {
 	// 判断 WHERE 条件是否为空
 	if q.tableEnableCache {
 		tableStruct := q.getTableStruct()
-		if tableStruct.EnableCache && q.where == "" && len(q.whereM) == 0 {
+		if tableStruct != nil && tableStruct.EnableCache && q.where == "" && len(q.whereM) == 0 && len(q.whereJSON) == 0 && len(q.joins) == 0 && len(q.primaryArgs) == 0 {
 			return q.tableData[q.table].Len(), nil
 		}
+		if len(q.whereJSON) > 0 && q.memory_enabled(tableStruct) {
+			return int64(len(q.memory_rows(tableStruct))), nil
+		}
 	}
-	q.Fields("COUNT(*)")
-	sql1, args := q.toSQL(nil, ACTION_SELECT_ONE)
-	n, err = q.QueryRowScanX(sql1, args...)
+	n, err = q.scalar(Raw("COUNT(*)"))
 	return
 }
 
 // 针对某一列
 func (q *Query) Sum(colName string) (n int64, err error) {
 	defer dbxErrorDefer(&err, q)
-	q.Fields("SUM(" + colName + ")")
-	sql1, args := q.toSQL(nil, ACTION_SELECT_ONE)
-	n, err = q.QueryRowScanX(sql1, args...)
+	n, err = q.scalar(Raw("SUM(" + q.colSQL(q.getTableStruct(), colName) + ")"))
 	return
 }
 
 // 针对某一列
 func (q *Query) Max(colName string) (n int64, err error) {
 	defer dbxErrorDefer(&err, q)
-	q.Fields("MAX(" + colName + ")")
-	sql1, args := q.toSQL(nil, ACTION_SELECT_ONE)
-	n, err = q.QueryRowScanX(sql1, args...)
+	n, err = q.scalar(Raw("MAX(" + q.colSQL(q.getTableStruct(), colName) + ")"))
 	return
 }
 
 // 针对某一列
 func (q *Query) Min(colName string) (n int64, err error) {
 	defer dbxErrorDefer(&err, q)
-	q.Fields("MIN(" + colName + ")")
-	sql1, args := q.toSQL(nil, ACTION_SELECT_ONE)
-	n, err = q.QueryRowScanX(sql1, args...)
+	n, err = q.scalar(Raw("MIN(" + q.colSQL(q.getTableStruct(), colName) + ")"))
+	return
+}
+
+// 在 q 的拷贝上执行，不修改 q 的 SELECT / ORDER BY / LIMIT，同一个 *Query 可以继续查询
+func (q *Query) scalar(field Expression) (n int64, err error) {
+	q2 := *q
+	q2.fields = []interface{}{field}
+	q2.selects = nil
+	q2.orderBy = M{}
+	q2.limitStart, q2.limitEnd = 0, 0
+	sql1, args := q2.toSQL(q.getTableStruct(), ACTION_SELECT_ONE)
+	n, err = q2.QueryRowScanX(sql1, args...)
 	return
 }
 
@@ -1003,9 +1265,9 @@ func (q *Query) Truncate() (err error) {
 
 	sql1 := ""
 	if q.DriverType == DRIVER_SQLITE {
-		sql1 = "DELETE FROM " + q.table
+		sql1 = "DELETE FROM " + q.tableSQL()
 	} else {
-		sql1 = "TRUNCATE " + q.table
+		sql1 = "TRUNCATE " + q.tableSQL()
 	}
 	_, err = q.Exec(sql1)
 	if err != nil {
@@ -1042,6 +1304,8 @@ func (q *Query) getTableStruct(arrTypes ...reflect.Type) (tableStruct *TableStru
 		tableStruct, ok = q.tableStruct[q.table]
 	}
 	if !ok {
+		// 查询时传入的表名，Bind() 的表名来自数据库，只加引号
+		check_ident("table", q.table)
 		tableStruct = NewTableStruct(q.DB, q.table, arrType)
 		q.tableStruct[q.table] = tableStruct
 	}
@@ -1054,8 +1318,9 @@ func (q *Query) Insert(ifc interface{}) (insertId int64, err error) {
 }
 
 // ifc 最好为 &struct
+// REPLACE INTO 会先删除冲突的行再插入，只更新部分列时使用 Upsert()
 func (q *Query) Replace(ifc interface{}) (insertId int64, err error) {
-	q.insert_replace(ifc, true, false)
+	return q.insert_replace(ifc, true, false)
 	//
 	//if q.DriverType == DRIVER_CQL {
 	//	tableStruct := q.getTableStruct()
@@ -1068,7 +1333,6 @@ func (q *Query) Replace(ifc interface{}) (insertId int64, err error) {
 	//} else {
 	//	q.insert_replace(ifc, true, false)
 	//}
-	return
 }
 
 // ifc 最好为 &struct
@@ -1169,6 +1433,9 @@ func (q *Query) insert_replace(ifc interface{}, isReplace bool, ignore bool) (in
 
 			//ifc2 = ifcValueP.Interface()
 		}
+		if tableStruct.reloadAfterWrite {
+			q.reload_row(tableStruct, ifcValueP)
+		}
 		pkkey := get_pk_keys(tableStruct, arrValue)
 		mp.Store(pkkey, ifc2)
 	}
@@ -1240,12 +1507,27 @@ func (q *Query) Update(ifc interface{}) (affectedRows int64, err error) {
 		//	return true
 		//})
 		//fmt.Printf("Len: %v, 1: %v", mp.Len(), v)
+		if tableStruct.reloadAfterWrite {
+			q.reload_row(tableStruct, ifcValueP)
+		}
 		mp.Store(pkkey, ifc2)
 	}
 
 	return
 }
 
+// 按照主键从数据库重新读取一行，readonly / omitempty 的列由数据库决定，写入后保持缓存一致
+func (q *Query) reload_row(tableStruct *TableStruct, rowP reflect.Value) {
+	pkValues := get_pk_values(tableStruct, rowP.Elem(), q.isCQL)
+	fields := arr_to_sql_add(tableStruct.ColFieldMap.colArr, "", ",", q.isCQL)
+	where := arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
+	sql1 := fmt.Sprintf("SELECT %v FROM %v WHERE %v", fields, q.tableSQL(), where)
+	err := q.get_row_by_sql(rowP, tableStruct, sql1, pkValues...)
+	if err != nil {
+		q.Panic("reload row after write: %v", err.Error())
+	}
+}
+
 //// 根据主键更新最简单
 //if isPK {
 //	// 直接获取原来的值
@@ -1303,26 +1585,52 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 	tableStruct := q.getTableStruct()
 
 	// 更新相关的信息
-	updateFields := make([]string, len(m))
-	updateOps := make([]string, len(m))
-	updateArgs := make([]interface{}, len(m))
+	updateFields := make([]string, 0, len(m))
+	updateOps := make([]string, 0, len(m))
+	updateArgs := make([]interface{}, 0, len(m))
 	euqalOpcode := true
-	for i, m := range m {
+	hasExpr := false
+	for _, m := range m {
 		if in_array(m.Key, tableStruct.PrimaryKey) {
 			//return 0, errors.New("you can't update primary key, you can remove it first.")
 			continue
 		}
+		field, op, value := m.Key, "=", m.Value
 		opcode := m.Key[len(m.Key)-1:]
-		if opcode == "+" || opcode == "-" || opcode == "*" || opcode == "%" || opcode == "=" {
-			updateOps[i] = opcode
-			updateFields[i] = m.Key[0 : len(m.Key)-1]
+		if strings.Contains("+-*/%<>=", opcode) {
+			field, op = m.Key[0:len(m.Key)-1], opcode
 			euqalOpcode = false
-		} else {
-			updateOps[i] = "="
-			updateFields[i] = m.Key
 		}
-		updateArgs[i] = m.Value
+		col := tableStruct.ColFieldMap.GetByColName(field)
+		// 只读列不更新
+		if col != nil && col.ReadOnly {
+			continue
+		}
+		if is_sql_value(value) {
+			if op != "=" {
+				q.Panic("UpdateM(): expression can only be used with =: %v", m.Key)
+			}
+			hasExpr = true
+		} else if col != nil && op != "=" {
+			// 运算的参数转换为字段的类型，缓存和 SQL 的结果一致
+			t := col.FieldStruct.Type
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			v := arg_to_type(value, t)
+			if (op == "/" || op == "%") && v.IsZero() {
+				q.Panic("UpdateM(): division by zero: %v", m.Key)
+			}
+			value = v.Interface()
+		}
+		updateFields = append(updateFields, field)
+		updateOps = append(updateOps, op)
+		updateArgs = append(updateArgs, value)
 	}
+	if len(updateFields) == 0 {
+		return
+	}
+	q = q.Clone()
 	q.updateFields = updateFields
 	q.updateOps = updateOps
 	q.updateArgs = updateArgs
@@ -1332,14 +1640,17 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 	//isPK := len(q.primaryKeyStr) > 0
 	cacheOn := q.tableEnableCache && tableStruct.EnableCache
 	isCQL := q.isCQL
+	if isCQL && cacheOn && hasExpr && !euqalOpcode {
+		q.Panic("UpdateM(): expression can not be mixed with operators on cached Cassandra table")
+	}
 
 	var mp *syncmap.Map
 	var listValue reflect.Value
-	poses := make([][]int, len(updateFields))
+	cols := make([]*Col, len(updateFields))
 	if cacheOn || isCQL {
 		where2, args2, allowFiltering := q.whereToSQL(tableStruct)
 		fields2 := arr_to_sql_add(tableStruct.PrimaryKey, "", ",", q.isCQL)
-		sql2 := fmt.Sprintf("SELECT %v FROM %v%v%v", fields2, q.table, where2, allowFiltering)
+		sql2 := fmt.Sprintf("SELECT %v FROM %v%v%v", fields2, q.tableSQL(), where2, allowFiltering)
 		listValue, err = q.get_list_by_sql(sql2, args2...)
 		if err != nil {
 			return
@@ -1360,8 +1671,15 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 				q.ErrorLog("UpdateM() colNmae does not exists: " + colName)
 				continue
 			}
-			col := tableStruct.ColFieldMap.cols[n]
-			poses[k] = col.FieldPos
+			cols[k] = tableStruct.ColFieldMap.cols[n]
+		}
+	}
+	if cacheOn && !euqalOpcode {
+		// 先在副本上计算一遍，溢出等错误在修改缓存、执行 SQL 之前返回
+		for i := 0; i < listValue.Len(); i++ {
+			if old, ok := mp.Load(get_pk_keys(tableStruct, listValue.Index(i).Elem())); ok {
+				check_update_ops(tableStruct, reflect.ValueOf(old).Elem(), cols, updateOps, updateArgs)
+			}
 		}
 	}
 	if cacheOn {
@@ -1383,13 +1701,23 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 				//fmt.Printf("old: %#v\n", old)
 				updateNewArgs := make([]interface{}, 0)
 				for j, _ := range updateFields {
-					pos := poses[j]
-					var oldV reflect.Value // 更新旧值，从 map 里面反射过来
-					oldV = get_reflect_value_from_pos(reflect.ValueOf(old).Elem(), pos)
-
-					if updateOps[j] == "=" {
-						set_value_to_ifc(oldV, updateArgs[j])
+					col := cols[j]
+					if col == nil {
+						continue
+					}
+					if is_sql_value(updateArgs[j]) {
+						// 更新之后从数据库读取
+						continue
+					} else if updateOps[j] == "=" {
+						// 字段本身（不解引用指针），NULL 与数据库的行为一致
+						oldF := get_reflect_field_from_pos(reflect.ValueOf(old).Elem(), col.FieldPos)
+						set_col_value(tableStruct, col, oldF, updateArgs[j])
+						if isCQL && !euqalOpcode {
+							updateNewArgs = append(updateNewArgs, updateArgs[j])
+						}
 					} else {
+						var oldV reflect.Value // 更新旧值，从 map 里面反射过来
+						oldV = get_reflect_value_from_pos(reflect.ValueOf(old).Elem(), col.FieldPos)
 						// Cassandra 不支持！非 =
 						set_value_to_ifc_int(oldV, updateOps[j], updateArgs[j])
 						// 写入 CQL
@@ -1402,7 +1730,7 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 				if isCQL && !euqalOpcode {
 					// 按照行更新
 					where := arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
-					sql3 := fmt.Sprintf("UPDATE %v SET %v WHERE %v", q.table, updateSets, where)
+					sql3 := fmt.Sprintf("UPDATE %v SET %v WHERE %v", q.tableSQL(), updateSets, where)
 					updateNewArgs = append(updateNewArgs, pkValues...)
 					affectedRows, err = q.Exec(sql3, updateNewArgs...)
 				}
@@ -1415,9 +1743,9 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 			for i := 0; i < listValue.Len(); i++ {
 				row := listValue.Index(i) // 只有主键的数据
 				pkValues := get_pk_values(tableStruct, row.Elem(), q.isCQL)
-				q.WherePK(pkValues...) // todo: 不是很优雅，如果需要再次使用可以重复调用
-				sql1, args := q.toSQL(tableStruct, ACTION_UPDATE_M)
-				affectedRows, err = q.Exec(sql1, args...)
+				q2 := q.WherePK(pkValues...)
+				sql1, args := q2.toSQL(tableStruct, ACTION_UPDATE_M)
+				affectedRows, err = q2.Exec(sql1, args...)
 			}
 		}
 	} else {
@@ -1425,9 +1753,58 @@ func (q *Query) UpdateM(m M) (affectedRows int64, err error) {
 		sql1, args := q.toSQL(tableStruct, ACTION_UPDATE_M)
 		affectedRows, err = q.Exec(sql1, args...)
 	}
+	// 表达式的结果只有数据库知道，更新之后重新读取这些行
+	if cacheOn && hasExpr && err == nil {
+		q.reload_cache_rows(tableStruct, mp, listValue)
+	}
 	return
 }
 
+// 在字段值的副本上执行 UpdateM() 的运算，出错时 panic，不修改 row
+func check_update_ops(tableStruct *TableStruct, row reflect.Value, cols []*Col, ops []string, args []interface{}) {
+	values := map[*Col]reflect.Value{}
+	for j, col := range cols {
+		if col == nil || is_sql_value(args[j]) {
+			continue
+		}
+		t := col.FieldStruct.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		v, ok := values[col]
+		if !ok {
+			v = reflect.New(t).Elem()
+			if old := get_value_from_pos(row, col.FieldPos); old != nil {
+				v.Set(reflect.ValueOf(old))
+			}
+			values[col] = v
+		}
+		if ops[j] != "=" {
+			set_value_to_ifc_int(v, ops[j], args[j])
+		} else if args[j] == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			set_col_value(tableStruct, col, v, args[j])
+		}
+	}
+}
+
+// 按照主键从数据库读取 rows，替换缓存中的行
+func (q *Query) reload_cache_rows(tableStruct *TableStruct, mp *syncmap.Map, rows reflect.Value) {
+	where := arr_to_sql_add(tableStruct.PrimaryKey, "=?", " AND ", q.isCQL)
+	sql1 := fmt.Sprintf("SELECT * FROM %v WHERE %v", q.tableSQL(), where)
+	for i := 0; i < rows.Len(); i++ {
+		rowElem := rows.Index(i).Elem()
+		list, err := q.get_list_by_sql(sql1, get_pk_values(tableStruct, rowElem, q.isCQL)...)
+		if err != nil {
+			panic(dbxErrorNew("reload cache failed: %v", err))
+		}
+		if list.Elem().Len() > 0 {
+			mp.Store(get_pk_keys(tableStruct, rowElem), list.Elem().Index(0).Interface())
+		}
+	}
+}
+
 
 
 func (db *DB) Exec(sql1 string, args ...interface{}) (n int64, err error) {
@@ -1447,7 +1824,7 @@ func (db *DB) Exec(sql1 string, args ...interface{}) (n int64, err error) {
 			return
 		}
 		prefix := strings.ToUpper(sql1[0:6])
-		if prefix == "INSERT" {
+		if prefix == "INSERT" || prefix == "REPLAC" { // REPLACE INTO
 			n, err = result.LastInsertId()
 			if err != nil {
 				db.ErrorSQL(err.Error(), sql1, args...)
@@ -1503,7 +1880,7 @@ func (q *Query) Delete() (n int64, err error) {
 	// 只更新一条
 	if isPK {
 		if cacheOn {
-			mp.Delete(q.primaryKeyStr)
+			mp.Delete(get_pk_key_by_args(tableStruct, q.primaryArgs))
 		}
 		sql1, args := q.toSQL(tableStruct, ACTION_DELETE)
 		n, err = q.Exec(sql1, args...)
@@ -1515,7 +1892,7 @@ func (q *Query) Delete() (n int64, err error) {
 		if cacheOn || isCQL {
 			pkColNames := tableStruct.PrimaryKey
 			fields2 := arr_to_sql_add(append(pkColNames), "", ",", q.isCQL)
-			sql2 := fmt.Sprintf("SELECT %v FROM %v%v%v", fields2, q.table, where2, allowFiltering)
+			sql2 := fmt.Sprintf("SELECT %v FROM %v%v%v", fields2, q.tableSQL(), where2, allowFiltering)
 			listValue, err = q.get_list_by_sql(sql2, args2...)
 			listValue = listValue.Elem()
 		}
@@ -1532,9 +1909,9 @@ func (q *Query) Delete() (n int64, err error) {
 			for i := 0; i < listValue.Len(); i++ {
 				row := listValue.Index(i)
 				pkValues := get_pk_values(tableStruct, row.Elem(), q.isCQL)
-				q.WherePK(pkValues...)
-				sql1, args := q.toSQL(tableStruct, ACTION_DELETE)
-				_, err = q.Exec(sql1, args...)
+				q2 := q.WherePK(pkValues...)
+				sql1, args := q2.toSQL(tableStruct, ACTION_DELETE)
+				_, err = q2.Exec(sql1, args...)
 			}
 		} else {
 			sql1, args := q.toSQL(tableStruct, ACTION_DELETE)
@@ -1586,20 +1963,29 @@ func (q *Query) get_row_by_sql(arrValue reflect.Value, tableStruct *TableStruct,
 
 		// 数据库返回的列，需要和表结构进行对应
 		defer rows.Close()
-		columns = cql_columns(rows.Columns())
+		infos := rows.Columns()
+		columns = cql_columns(infos)
 		values := make([]interface{}, len(columns))
 		//refVals := make([]reflect.Value, len(columns))
 
-		posMap := map[int][]int{}
+		posMap := map[int]*Col{}
 		for k, colName := range columns {
 			n, ok := tableStruct.ColFieldMap.colMap[colName]
 			if !ok {
 				continue
 			}
 			col := tableStruct.ColFieldMap.cols[n]
-			posMap[k] = col.FieldPos
+			posMap[k] = col
 			//refVals[k] = reflect.New(col.FieldStruct.Type).Elem()
-			values[k] = reflect.New(col.FieldStruct.Type).Interface()
+			values[k] = cql_scan_dest(col, infos[k])
+		}
+
+		if m := row_to_mapper(tableStruct, arrValue); m != nil {
+			mapper_scan_dest(m, columns, values, make([]interface{}, len(columns)))
+			if b := rows.Scan(values...); !b {
+				err = sql.ErrNoRows
+			}
+			return
 		}
 
 		if b := rows.Scan(values...); !b {
@@ -1608,13 +1994,13 @@ func (q *Query) get_row_by_sql(arrValue reflect.Value, tableStruct *TableStruct,
 		}
 		// 对应到相应的列
 		for k, _ := range columns {
-			pos, ok := posMap[k]
+			col, ok := posMap[k]
 			if !ok {
 				continue
 			}
-			posValue := get_reflect_value_from_pos(arrValue, pos) // 需要设置的字段
+			posValue := get_reflect_field_from_pos(arrValue, col.FieldPos) // 需要设置的字段
 			//set_value_to_ifc(posValue, values[k])
-			set_value_to_ifc(posValue, reflect.ValueOf(values[k]).Elem().Interface())
+			set_col_value(tableStruct, col, posValue, reflect.ValueOf(values[k]).Elem().Interface())
 		}
 		return
 
@@ -1633,50 +2019,54 @@ func (q *Query) get_row_by_sql(arrValue reflect.Value, tableStruct *TableStruct,
 			return
 		}
 
-		posMap := map[int][]int{}
+		posMap := map[int]*Col{}
 		for k, colName := range columns {
 			n, ok := tableStruct.ColFieldMap.colMap[colName]
 			if !ok {
 				continue
 			}
 			col := tableStruct.ColFieldMap.cols[n]
-			posMap[k] = col.FieldPos
-			values[k] = reflect.New(col.FieldStruct.Type).Interface()
+			posMap[k] = col
 		}
 
-		//values := make([]interface{}, len(columns))
-		//for i := range values {
-		//	values[i] = new(interface{})
-		//}
+		// 与 rows_to_arr_list() 相同，Scan 到 interface{}，NULL 由 set_col_value() 处理
+		holders := sql_scan_holders(len(columns))
+		copy(values, holders)
 
 		if !rows.Next() {
 			err = sql.ErrNoRows
 			return
 		}
+		m := row_to_mapper(tableStruct, arrValue)
+		if m != nil {
+			mapper_scan_dest(m, columns, values, holders)
+		}
 		err = rows.Scan(values...)
 		if err != nil {
 			q.ErrorSQL(err.Error(), sql1, args...)
 			return
 		}
-		// 对应到相应的列
-		for k, _ := range columns {
-			pos, ok := posMap[k]
-			if !ok {
-				continue
-			}
+		// 对应到相应的列，Mapper 已经直接写入了字段
+		if m == nil {
+			for k, _ := range columns {
+				col, ok := posMap[k]
+				if !ok {
+					continue
+				}
 
-			posValue := get_reflect_value_from_pos(arrValue, pos) // 需要设置的字段
-			// set_value_to_ifc(posValue, values[k])
-			set_value_to_ifc(posValue, reflect.ValueOf(values[k]).Elem().Interface())
+				posValue := get_reflect_field_from_pos(arrValue, col.FieldPos) // 需要设置的字段
+				// set_value_to_ifc(posValue, values[k])
+				set_col_value(tableStruct, col, posValue, reflect.ValueOf(values[k]).Elem().Interface())
 
-			////ifc_pos_to_value(values[k], pos, arrValue)
-			//ifc := *(values[k].(*interface{})) // db 里面取出来的数据
-			////valueV := reflect.ValueOf(value)
-			////valueKind := valueV.Kind()
-			//col := get_reflect_value_from_pos(arrValue.Elem(), pos) // 需要设置的字段
-			//
-			//set_value_to_ifc(col, ifc)
+				////ifc_pos_to_value(values[k], pos, arrValue)
+				//ifc := *(values[k].(*interface{})) // db 里面取出来的数据
+				////valueV := reflect.ValueOf(value)
+				////valueKind := valueV.Kind()
+				//col := get_reflect_value_from_pos(arrValue.Elem(), pos) // 需要设置的字段
+				//
+				//set_value_to_ifc(col, ifc)
 
+			}
 		}
 
 		err = rows.Err()
@@ -59,6 +59,17 @@ func main() {
 		panic(err)
 	}
 
+	// 批量插入，比逐行 Insert() 快得多
+	t0 := time.Now()
+	list := make([]*User, 0, 40000)
+	for i := 2; i <= 40000; i++ {
+		list = append(list, &User{Gid: int64(i % 10), Name: fmt.Sprintf("user%v", i), CreateDate: time.Now()})
+	}
+	_, err = db.Table("user").InsertBatch(list)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("InsertBatch: %v\n", time.Now().Sub(t0))
 
 	db.Bind("user", &User{}, true)
 	db.EnableCache(false)
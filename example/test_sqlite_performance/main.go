@@ -7,17 +7,6 @@ import (
 	"time"
 )
 
-type Human struct {
-	Uid        int64     `db:"uid"`
-	Gid        int64     `db:"gid"`
-}
-
-type User struct {
-	Human
-	Name       string    `db:"name"`
-	CreateDate time.Time `db:"createDate"`
-}
-
 func main() {
 
 	var err error
@@ -43,21 +32,10 @@ func main() {
 	//db.Exec("PRAGMA wal_autocheckpoint=100;")
 
 	// 创建表
-	_, err = db.Exec(`
-		DROP TABLE IF EXISTS user;
-		CREATE TABLE user
-		(
-		  uid        INTEGER PRIMARY KEY AUTOINCREMENT,
-		  gid        INTEGER NOT NULL DEFAULT '0',
-		  name       TEXT             DEFAULT '',
-		  createDate DATETIME         DEFAULT CURRENT_TIMESTAMP
-		);`)
-	if err != nil {
-		panic(err)
-	}
+	createTable(db, "user")
 
 	// 插入一条
-	u1 := &User{Human{1, 1}, "jack", time.Now()}
+	u1 := &User{Human{1, 1}, "jack", time.Now().Unix()}
 	_, err = db.Table("user").Insert(u1)
 	if err != nil {
 		panic(err)
@@ -74,5 +52,58 @@ func main() {
 		db.Table("user").WherePK(i).One(u2)
 	}
 	fmt.Printf("%v\n", time.Now().Sub(t1))
+
+	// 反射 vs Mapper: user_fast 绑定了实现 dbx.Mapper 的 UserFast
+	createTable(db, "user_fast")
+	fillTable(db, "user", 10000)
+	fillTable(db, "user_fast", 10000)
+	db.Bind("user_fast", &UserFast{}, false)
+
+	t1 = time.Now()
+	for i := 0; i < 20; i++ {
+		list := []*User{}
+		db.Table("user").All(&list)
+	}
+	fmt.Printf("All() reflect: %v\n", time.Now().Sub(t1))
+
+	t1 = time.Now()
+	for i := 0; i < 20; i++ {
+		list := []*UserFast{}
+		db.Table("user_fast").All(&list)
+	}
+	fmt.Printf("All() mapper:  %v\n", time.Now().Sub(t1))
 	return
 }
+
+func createTable(db *dbx.DB, tableName string) {
+	_, err := db.Exec(`
+		DROP TABLE IF EXISTS ` + tableName + `;
+		CREATE TABLE ` + tableName + `
+		(
+		  uid        INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid        INTEGER NOT NULL DEFAULT '0',
+		  name       TEXT             DEFAULT '',
+		  createDate INTEGER NOT NULL DEFAULT '0'
+		);`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func fillTable(db *dbx.DB, tableName string, n int) {
+	db.Exec("DELETE FROM " + tableName)
+	tx, err := db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	for i := 1; i <= n; i++ {
+		_, err = tx.Exec("INSERT INTO "+tableName+" (uid, gid, name, createDate) VALUES (?, ?, ?, ?)", i, i%10, fmt.Sprintf("name-%v", i), now.Unix())
+		if err != nil {
+			panic(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
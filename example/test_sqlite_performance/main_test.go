@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/xiuno/dbx"
+)
+
+// go test -bench . -benchmem
+// 对比反射和生成的 Mapper: All() 以及 loadTableCache()
+
+func openBench(b *testing.B) *dbx.DB {
+	db, err := dbx.Open("sqlite3", "./db_bench.db?cache=shared&mode=rwc&parseTime=true&charset=utf8")
+	if err != nil {
+		b.Fatal(err)
+	}
+	db.SetMaxIdleConns(1)
+	db.SetMaxOpenConns(1)
+	createTable(db, "user")
+	createTable(db, "user_fast")
+	fillTable(db, "user", 2000)
+	fillTable(db, "user_fast", 2000)
+	db.Bind("user", &User{}, true)
+	db.Bind("user_fast", &UserFast{}, true)
+	return db
+}
+
+func BenchmarkAllReflect(b *testing.B) {
+	db := openBench(b)
+	defer db.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list := []*User{}
+		if err := db.Table("user").All(&list); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAllMapper(b *testing.B) {
+	db := openBench(b)
+	defer db.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list := []*UserFast{}
+		if err := db.Table("user_fast").All(&list); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadCacheReflect(b *testing.B) {
+	db := openBench(b)
+	defer db.Close()
+	db.EnableCache(true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Table("user").LoadCache()
+	}
+}
+
+func BenchmarkLoadCacheMapper(b *testing.B) {
+	db := openBench(b)
+	defer db.Close()
+	db.EnableCache(true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Table("user_fast").LoadCache()
+	}
+}
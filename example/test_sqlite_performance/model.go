@@ -0,0 +1,21 @@
+package main
+
+//go:generate dbx mapper --file model.go --type UserFast
+
+type Human struct {
+	Uid        int64     `db:"uid"`
+	Gid        int64     `db:"gid"`
+}
+
+type User struct {
+	Human
+	Name       string    `db:"name"`
+	CreateDate int64     `db:"createDate"`
+}
+
+// 与 User 相同，实现了 dbx.Mapper（model_dbx.go），读写不反射
+type UserFast struct {
+	Human
+	Name       string    `db:"name"`
+	CreateDate int64     `db:"createDate"` // time.Time 需要按照 TimePolicy 转换，Mapper 会退回到反射
+}
@@ -0,0 +1,434 @@
+package dbx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// 已绑定的表导出、导入 CSV / JSON lines，按照 ColFieldMap 对应列名：
+//
+//	n, err := db.Table("user").Where("gid=?", 1).ExportCSV(w)
+//	n, err := db.Table("user").Fields("uid", "name").ExportJSONL(w)
+//	res, err := db.Table("user").ImportCSV(r, dbx.ImportOpts{BatchOpts: dbx.BatchOpts{OnConflict: dbx.OnConflict()}})
+//
+// 导出逐行读取，不把整个表放到内存中。CSV 中 NULL 为空字符串，时间为 RFC3339，json 列为 JSON 字符串，
+// 二进制的列为 base64，注册了转换的类型为写入时转换后的值；JSON lines 每行一个对象，值与 encoding/json 相同。
+type ImportOpts struct {
+	BatchOpts      // 每批的行数、Ignore、OnConflict
+	MaxErrors int  // 出错的行超过 MaxErrors 时停止，默认 0 不限制
+	Comma     rune // CSV 的分隔符，默认 ','
+
+	// 插入数据中非 0 的自增列的值，导出再导入后 id 不变；默认与 InsertBatch() 相同，由数据库生成
+	KeepAutoIncrement bool
+}
+
+// 导入的结果，出错的行被跳过
+type ImportResult struct {
+	Rows   int64        // 写入的行数，Ignore 时包括被跳过的冲突的行
+	Errors []*LineError // 出错的行，按照行号排序
+}
+
+type LineError struct {
+	Line int // 从 1 开始，CSV 的表头为第 1 行
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// 导出的列：Fields() 中的列名，默认为全部的列
+func (q *Query) export_columns(fn string) (tableStruct *TableStruct, cols []*Col) {
+	tableStruct = q.getTableStruct()
+	if tableStruct == nil {
+		q.Panic("%v(): table %v is not bound", fn, q.table)
+	}
+	if len(q.fields) == 0 {
+		for _, colName := range tableStruct.ColFieldMap.colArr {
+			cols = append(cols, tableStruct.ColFieldMap.GetByColName(colName))
+		}
+		return
+	}
+	for _, f := range q.fields {
+		colName, ok := f.(string)
+		col := tableStruct.ColFieldMap.GetByColName(colName)
+		if !ok || col == nil {
+			q.Panic("%v(): fields must be columns of table %v: %v", fn, q.table, f)
+		}
+		cols = append(cols, col)
+	}
+	return
+}
+
+// 逐行读取，fn 返回错误时结束
+func (q *Query) export_rows(tableStruct *TableStruct, fn func(row reflect.Value) error) (n int64, err error) {
+	var it *Iter
+	if it, err = q.Iter(context.Background()); err != nil {
+		return
+	}
+	defer it.Close()
+	rowP := reflect.New(tableStruct.Type.Elem())
+	for it.Next() {
+		if err = it.Scan(rowP.Interface()); err != nil {
+			return
+		}
+		if err = fn(rowP.Elem()); err != nil {
+			return
+		}
+		n++
+	}
+	err = it.Err()
+	return
+}
+
+// 第一行为列名，返回导出的行数
+func (q *Query) ExportCSV(w io.Writer) (n int64, err error) {
+	defer dbxErrorDefer(&err, q)
+	tableStruct, cols := q.export_columns("ExportCSV")
+	cw := csv.NewWriter(w)
+	record := make([]string, len(cols))
+	for i, col := range cols {
+		record[i] = col.ColName
+	}
+	if err = cw.Write(record); err != nil {
+		return
+	}
+	n, err = q.export_rows(tableStruct, func(row reflect.Value) error {
+		for i, col := range cols {
+			s, err := value_to_csv(get_value_from_pos(row, col.FieldPos), col.JSON)
+			if err != nil {
+				return fmt.Errorf("column %v: %v", col.ColName, err)
+			}
+			record[i] = s
+		}
+		return cw.Write(record)
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return
+}
+
+// 每行一个 JSON 对象，key 的顺序与列的顺序相同
+func (q *Query) ExportJSONL(w io.Writer) (n int64, err error) {
+	defer dbxErrorDefer(&err, q)
+	tableStruct, cols := q.export_columns("ExportJSONL")
+	bw := bufio.NewWriter(w)
+	var buf bytes.Buffer
+	n, err = q.export_rows(tableStruct, func(row reflect.Value) error {
+		buf.Reset()
+		buf.WriteByte('{')
+		for i, col := range cols {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(col.ColName)
+			b, err := json.Marshal(get_value_from_pos(row, col.FieldPos))
+			if err != nil {
+				return fmt.Errorf("column %v: %v", col.ColName, err)
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(b)
+		}
+		buf.WriteString("}\n")
+		_, err := bw.Write(buf.Bytes())
+		return err
+	})
+	if err2 := bw.Flush(); err == nil {
+		err = err2
+	}
+	return
+}
+
+// 第一行为列名，列名也可以为结构体的字段名；不存在的列返回 *IdentifierError
+func (q *Query) ImportCSV(r io.Reader, opts ...ImportOpts) (res ImportResult, err error) {
+	defer dbxErrorDefer(&err, q)
+	opt, tableStruct := q.import_init("ImportCSV", opts)
+	cr := csv.NewReader(r)
+	if opt.Comma != 0 {
+		cr.Comma = opt.Comma
+	}
+	var header []string
+	if header, err = cr.Read(); err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return
+	}
+	cols := make([]*Col, len(header))
+	for i, name := range header {
+		if cols[i] = import_col(tableStruct, name); cols[i] == nil {
+			panic(dbxErrorWrap(&IdentifierError{Kind: "column", Name: name, Table: q.table}))
+		}
+	}
+	return q.import_rows(tableStruct, opt, func(rowP reflect.Value) (int, error) {
+		record, err := cr.Read()
+		if err != nil {
+			// 列数不对等格式错误，跳过这一行
+			if pe, ok := err.(*csv.ParseError); ok {
+				return pe.StartLine, &LineError{Line: pe.StartLine, Err: pe.Err}
+			}
+			return 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		for i, s := range record {
+			v, err := csv_to_value(s, cols[i].FieldStruct.Type, cols[i].JSON)
+			if err != nil {
+				return line, &LineError{Line: line, Err: fmt.Errorf("column %v: %v", cols[i].ColName, err)}
+			}
+			get_reflect_field_from_pos(rowP, cols[i].FieldPos).Set(v)
+		}
+		return line, nil
+	})
+}
+
+// 每行一个 JSON 对象，空行跳过
+func (q *Query) ImportJSONL(r io.Reader, opts ...ImportOpts) (res ImportResult, err error) {
+	defer dbxErrorDefer(&err, q)
+	opt, tableStruct := q.import_init("ImportJSONL", opts)
+	br := bufio.NewReader(r)
+	line := 0
+	return q.import_rows(tableStruct, opt, func(rowP reflect.Value) (int, error) {
+		for {
+			b, err := br.ReadBytes('\n')
+			if len(b) == 0 && err != nil {
+				return line, err
+			}
+			line++
+			b = bytes.TrimSpace(b)
+			if len(b) == 0 {
+				continue
+			}
+			m := map[string]json.RawMessage{}
+			if err := json.Unmarshal(b, &m); err != nil {
+				return line, &LineError{Line: line, Err: err}
+			}
+			for name, raw := range m {
+				col := import_col(tableStruct, name)
+				if col == nil {
+					return line, &LineError{Line: line, Err: &IdentifierError{Kind: "column", Name: name, Table: q.table}}
+				}
+				if err := json.Unmarshal(raw, get_reflect_field_from_pos(rowP, col.FieldPos).Addr().Interface()); err != nil {
+					return line, &LineError{Line: line, Err: fmt.Errorf("column %v: %v", name, err)}
+				}
+			}
+			return line, nil
+		}
+	})
+}
+
+func (q *Query) import_init(fn string, opts []ImportOpts) (opt ImportOpts, tableStruct *TableStruct) {
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	tableStruct = q.getTableStruct()
+	if tableStruct == nil {
+		q.Panic("%v(): table %v is not bound", fn, q.table)
+	}
+	if opt.Ignore && opt.OnConflict != nil {
+		q.Panic("%v(): Ignore can not be used with OnConflict", fn)
+	}
+	if opt.Ignore && q.isCQL {
+		q.Panic("%v(): Cassandra does not support Ignore", fn)
+	}
+	return
+}
+
+func import_col(tableStruct *TableStruct, name string) *Col {
+	if col := tableStruct.ColFieldMap.GetByColName(name); col != nil {
+		return col
+	}
+	if col := tableStruct.ColFieldMap.GetByFieldName(name); col != nil && col.ColName != "" {
+		return col
+	}
+	return nil
+}
+
+// next 读取下一行到 rowP，返回 io.EOF 时结束，返回 *LineError 时跳过这一行。
+// 按照 Size 批量插入，一批出错时其中还没有写入的行逐行重试，找出出错的行。
+func (q *Query) import_rows(tableStruct *TableStruct, opt ImportOpts, next func(rowP reflect.Value) (int, error)) (res ImportResult, err error) {
+	if q.readOnly {
+		return
+	}
+	size, _ := q.batch_limits(opt.BatchOpts)
+	rows := make([]reflect.Value, 0, size)
+	lines := make([]int, 0, size)
+	addError := func(e *LineError) error {
+		res.Errors = append(res.Errors, e)
+		if opt.MaxErrors > 0 && len(res.Errors) > opt.MaxErrors {
+			return fmt.Errorf("dbx: import stopped after %v errors, last: %v", len(res.Errors), e)
+		}
+		return nil
+	}
+	flush := func() error {
+		defer func() {
+			rows, lines = rows[:0], lines[:0]
+		}()
+		_, done, err := q.insert_batch_rows(tableStruct, rows, true, opt.BatchOpts, opt.KeepAutoIncrement)
+		res.Rows += int64(done)
+		if err == nil {
+			return nil
+		}
+		for i := done; i < len(rows); i++ {
+			if _, _, err = q.insert_batch_rows(tableStruct, rows[i:i+1], true, opt.BatchOpts, opt.KeepAutoIncrement); err != nil {
+				if err = addError(&LineError{Line: lines[i], Err: err}); err != nil {
+					return err
+				}
+				continue
+			}
+			res.Rows++
+		}
+		return nil
+	}
+
+	defer func() {
+		// 批量重试时出错的行在后面，按照行号排序
+		sort.SliceStable(res.Errors, func(i, j int) bool {
+			return res.Errors[i].Line < res.Errors[j].Line
+		})
+		if (opt.Ignore || opt.OnConflict != nil) && q.tableEnableCache && tableStruct.EnableCache {
+			q.loadTableCache(q.table)
+		}
+	}()
+	for {
+		rowP := reflect.New(tableStruct.Type.Elem())
+		line, err2 := next(rowP)
+		if err2 == io.EOF {
+			break
+		}
+		if e, ok := err2.(*LineError); ok {
+			if err = addError(e); err != nil {
+				return
+			}
+			continue
+		}
+		if err2 != nil {
+			err = err2
+			return
+		}
+		rows = append(rows, rowP)
+		lines = append(lines, line)
+		if len(rows) >= size {
+			if err = flush(); err != nil {
+				return
+			}
+		}
+	}
+	if len(rows) > 0 {
+		err = flush()
+	}
+	return
+}
+
+// CSV 的单元格，NULL 为空字符串
+func value_to_csv(v interface{}, isJSON bool) (s string, err error) {
+	defer recover_csv_error(&err)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "", nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return "", nil
+	}
+	if isJSON {
+		b, err := json.Marshal(rv.Interface())
+		return string(b), err
+	}
+	// []byte 的字段、转换为 []byte 的类型为 base64，driver.Valuer 返回的 []byte 为字符串
+	binary := is_bytes_type(rv.Type()) || is_bytes_type(write_converter_type(rv.Type()))
+	v = value_to_arg(rv.Interface(), false)
+	av := reflect.ValueOf(v)
+	switch {
+	case !av.IsValid():
+		return "", nil
+	case av.Type() == timeType:
+		return v.(time.Time).Format(time.RFC3339Nano), nil
+	case is_bytes_type(av.Type()) && binary:
+		return base64.StdEncoding.EncodeToString(av.Bytes()), nil
+	case is_bytes_type(av.Type()):
+		return string(av.Bytes()), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// 空字符串为零值（指针为 nil），时间为 RFC3339 或者 2006-01-02 15:04:05
+func csv_to_value(s string, t reflect.Type, isJSON bool) (v reflect.Value, err error) {
+	defer recover_csv_error(&err)
+	if s == "" && t.Kind() != reflect.String {
+		return reflect.Zero(t), nil
+	}
+	if t.Kind() == reflect.Ptr {
+		v, err := csv_to_value(s, t.Elem(), isJSON)
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v)
+		return p, err
+	}
+	if isJSON {
+		return str_to_value(s, t, true)
+	}
+	// 注册了转换的类型，先转换为写入时的类型，再按照读取时的转换赋值
+	if to := write_converter_type(t); to != nil || has_converter_to(t) {
+		var src interface{} = s
+		if to != nil && to.Kind() != reflect.String {
+			v2, err := csv_to_value(s, to, false)
+			if err != nil {
+				return v2, err
+			}
+			src = v2.Interface()
+		}
+		v = reflect.New(t).Elem()
+		if convert_by_registry(v, src) {
+			return v, nil
+		}
+	}
+	if t == timeType {
+		if tm, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return reflect.ValueOf(tm), nil
+		}
+		return str_to_value(s, t, false)
+	}
+	if scanner, ok := reflect.New(t).Interface().(sql.Scanner); ok {
+		err := scanner.Scan(s)
+		return reflect.ValueOf(scanner).Elem(), err
+	}
+	if is_bytes_type(t) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return reflect.ValueOf(b).Convert(t), err
+	}
+	return str_to_value(s, t, false)
+}
+
+func is_bytes_type(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// 转换函数的错误作为这一行的错误返回
+func recover_csv_error(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*dbxError)
+		if !ok {
+			panic(r)
+		}
+		*err = errors.New(e.data)
+	}
+}
@@ -0,0 +1,189 @@
+package dbx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SQL 片段，原样拼接，不作为参数绑定；其中的 ? 对应 Args：
+//
+//	db.Table("user").WherePK(1).UpdateM(dbx.M{{"updated_at", dbx.Expr("NOW()")}, {"score", dbx.Expr("GREATEST(score,?)", 10)}})
+//	db.Table("user").Fields("gid", dbx.Raw("COUNT(*) AS n")).Sort(dbx.Expr("FIELD(gid,?,?)", 3, 1), 1)
+//
+// 子查询直接使用 *Query：
+//
+//	db.Table("user").Where("uid IN (?)", db.Table("vip").Fields("uid")).All(&list)
+//	db.Table("user").WhereM(dbx.M{{"uid IN", db.Table("vip").Fields("uid")}, {"gid >", 1}}).All(&list)
+type Expression struct {
+	SQL  string
+	Args []interface{}
+}
+
+func Expr(sql string, args ...interface{}) Expression {
+	return Expression{sql, args}
+}
+
+// 同 Expr()，用于原样输出的 SQL，例如 Raw("COUNT(*)")
+func Raw(sql string, args ...interface{}) Expression {
+	return Expression{sql, args}
+}
+
+// WhereM() 的 key 可以带运算符："uid IN"、"gid >"、"name LIKE"，没有时为 =
+var whereMOps = []string{"NOT IN", "IN", "NOT LIKE", "LIKE", ">=", "<=", "!=", "<>", "=", ">", "<"}
+
+func split_where_key(key string) (colName string, op string) {
+	key = strings.TrimSpace(key)
+	upper := strings.ToUpper(key)
+	for _, op := range whereMOps {
+		if !strings.HasSuffix(upper, op) {
+			continue
+		}
+		colName = strings.TrimSpace(key[:len(key)-len(op)])
+		// 单词运算符前面必须有空格：WHERE_IN 不是 IN
+		if op[0] >= 'A' && op[0] <= 'Z' && !strings.HasSuffix(key[:len(key)-len(op)], " ") {
+			continue
+		}
+		if colName != "" {
+			return colName, op
+		}
+	}
+	return key, "="
+}
+
+// 值为 Expression 或者子查询时展开为 SQL，其他为 ?
+func (q *Query) value_to_sql(v interface{}) (sql1 string, args []interface{}, ok bool) {
+	switch e := v.(type) {
+	case Expression:
+		return e.SQL, e.Args, true
+	case *Query:
+		if q.isCQL {
+			panic(dbxErrorNew("subquery is not supported by Cassandra"))
+		}
+		sql1, args = e.toSQL(e.getTableStruct(), ACTION_SELECT_ALL)
+		return sql1, args, true
+	}
+	return "?", []interface{}{v}, false
+}
+
+func is_sql_value(v interface{}) bool {
+	switch v.(type) {
+	case Expression, *Query:
+		return true
+	}
+	return false
+}
+
+// 将 str 中对应 Expression / *Query 的 ? 替换为 SQL，子查询没有括号时补充括号
+func (q *Query) expand_args(str string, args []interface{}) (string, []interface{}) {
+	expand := false
+	for _, v := range args {
+		if is_sql_value(v) {
+			expand = true
+			break
+		}
+	}
+	if !expand {
+		return str, args
+	}
+	var b strings.Builder
+	args2 := make([]interface{}, 0, len(args))
+	i := 0
+	for k := 0; k < len(str); k++ {
+		if str[k] != '?' || i >= len(args) {
+			b.WriteByte(str[k])
+			continue
+		}
+		sql1, a, _ := q.value_to_sql(args[i])
+		if _, ok := args[i].(*Query); ok && !(strings.HasSuffix(strings.TrimSpace(str[:k]), "(") && strings.HasPrefix(strings.TrimSpace(str[k+1:]), ")")) {
+			sql1 = "(" + sql1 + ")"
+		}
+		b.WriteString(sql1)
+		args2 = append(args2, a...)
+		i++
+	}
+	return b.String(), append(args2, args[i:]...)
+}
+
+// WhereM() 的条件
+func (q *Query) whereMToSQL() (where string, args []interface{}) {
+	arr := make([]string, 0, len(q.whereM))
+	for _, m := range q.whereM {
+		colName, op := split_where_key(m.Key)
+		col := q.colSQL(nil, colName)
+		if sql1, a, ok := q.value_to_sql(m.Value); ok {
+			if _, isQuery := m.Value.(*Query); isQuery {
+				sql1 = "(" + sql1 + ")"
+			}
+			arr = append(arr, fmt.Sprintf("%v %v %v", col, op, sql1))
+			args = append(args, a...)
+			continue
+		}
+		if op == "IN" || op == "NOT IN" {
+			values := in_values(m.Value)
+			if len(values) == 0 {
+				// IN () 语法错误：IN 为假，NOT IN 为真
+				if op == "IN" {
+					arr = append(arr, "1=0")
+				} else {
+					arr = append(arr, "1=1")
+				}
+				continue
+			}
+			arr = append(arr, fmt.Sprintf("%v %v (%v)", col, op, strings.TrimRight(strings.Repeat("?,", len(values)), ",")))
+			args = append(args, values...)
+			continue
+		}
+		if op == "=" {
+			arr = append(arr, col+"=?")
+		} else {
+			arr = append(arr, fmt.Sprintf("%v %v ?", col, op))
+		}
+		args = append(args, m.Value)
+	}
+	return strings.Join(arr, " AND "), args
+}
+
+// IN 的参数：slice / array 展开，[]byte 和单个值为一个参数
+func in_values(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []interface{}{v}
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+// 缓存中不能执行的条件：Expression、子查询、LIKE，以及 Sort() 中的表达式
+func (q *Query) has_sql_expr() bool {
+	for _, m := range q.whereM {
+		_, op := split_where_key(m.Key)
+		if is_sql_value(m.Value) || op == "LIKE" || op == "NOT LIKE" {
+			return true
+		}
+	}
+	for _, m := range q.orderBy {
+		if opt, ok := m.Value.(sortOpt); ok && opt.expr != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// 在缓存中执行 WhereM() 的一个条件
+func where_m_match(v interface{}, op string, value interface{}) bool {
+	switch op {
+	case "IN", "NOT IN":
+		for _, v2 := range in_values(value) {
+			if compare_op(v, "=", v2) {
+				return op == "IN"
+			}
+		}
+		// 与 SQL 一致，NULL NOT IN (...) 不匹配
+		return op == "NOT IN" && v != nil
+	}
+	return compare_op(v, op, value)
+}
@@ -0,0 +1,505 @@
+// 根据数据库中已有的表结构生成 Go 结构体和按表划分的 Repo，保证 db tag 与 DDL 一致。
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/xiuno/dbx"
+)
+
+type Options struct {
+	Out     string   // 输出目录: ./models
+	Package string   // 包名，默认为输出目录的名字
+	Tables  []string // 只生成指定的表，为空则生成全部
+	Mapper  bool     // 同时生成 dbx.Mapper 的实现: <table>_dbx.go
+}
+
+type Table struct {
+	Name       string // 表名: user
+	StructName string // 结构体名: User
+	Fields     []*Field
+	PK         []*Field
+}
+
+type Field struct {
+	Name          string // 结构体中的名字: Uid
+	Column        string // 列名: uid
+	Type          string // Go 类型: int64 / sql.NullString
+	Import        string // 类型需要的包: database/sql
+	PrimaryKey    bool
+	AutoIncrement bool
+	Nullable      bool
+}
+
+// 从数据库读取表结构
+func Load(db *dbx.DB, tables []string) (list []*Table, err error) {
+	if len(tables) == 0 {
+		tables, err = db.TableNames()
+		if err != nil {
+			return
+		}
+	}
+	list = make([]*Table, 0, len(tables))
+	for _, name := range tables {
+		var cols []*dbx.TableColumn
+		cols, err = db.TableColumns(name)
+		if err != nil {
+			return nil, fmt.Errorf("table %v: %v", name, err)
+		}
+		t := &Table{Name: name, StructName: StructName(name)}
+		fieldNames := map[string]string{}
+		for _, col := range cols {
+			typ, imp := GoType(db.DriverType, col)
+			f := &Field{
+				Name:          FieldName(col.Name),
+				Column:        col.Name,
+				Type:          typ,
+				Import:        imp,
+				PrimaryKey:    col.PrimaryKey,
+				AutoIncrement: col.AutoIncrement,
+				Nullable:      col.Nullable,
+			}
+			if other, ok := fieldNames[f.Name]; ok {
+				return nil, fmt.Errorf("table %v: columns %v and %v are both mapped to field %v", name, other, col.Name, f.Name)
+			}
+			fieldNames[f.Name] = col.Name
+			t.Fields = append(t.Fields, f)
+			if f.PrimaryKey {
+				t.PK = append(t.PK, f)
+			}
+		}
+		list = append(list, t)
+	}
+	err = checkNames(list)
+	return
+}
+
+// 同一个包中生成的标识符不能重复：结构体、<Name>Table、<Name>Repo、New<Name>Repo、Bind()
+func checkNames(tables []*Table) error {
+	names := map[string]string{"Bind": "func Bind"}
+	for _, t := range tables {
+		for _, name := range []string{t.StructName, t.StructName + "Table", t.StructName + "Repo", "New" + t.StructName + "Repo"} {
+			if other, ok := names[name]; ok {
+				return fmt.Errorf("table %v: generated name %v conflicts with %v", t.Name, name, other)
+			}
+			names[name] = "table " + t.Name
+		}
+	}
+	return nil
+}
+
+// 生成代码并写入 opts.Out，返回写入的文件列表
+func Generate(db *dbx.DB, opts Options) (files []string, err error) {
+	if opts.Out == "" {
+		opts.Out = "."
+	}
+	if opts.Package == "" {
+		abs, err := filepath.Abs(opts.Out)
+		if err != nil {
+			return nil, err
+		}
+		opts.Package = PackageName(filepath.Base(abs))
+	}
+	tables, err := Load(db, opts.Tables)
+	if err != nil {
+		return
+	}
+	// 先检查文件名，避免写了一半才发现冲突
+	fileNames := make([]string, len(tables))
+	used := map[string]string{"bind": "bind.go"}
+	for i, t := range tables {
+		fileNames[i] = FileName(t.Name)
+		for _, name := range []string{fileNames[i], fileNames[i] + "_dbx"} {
+			if other, ok := used[name]; ok {
+				return nil, fmt.Errorf("table %v: file %v.go conflicts with %v", t.Name, name, other)
+			}
+			used[name] = "table " + t.Name
+		}
+	}
+	if err = os.MkdirAll(opts.Out, 0755); err != nil {
+		return
+	}
+	for i, t := range tables {
+		var src []byte
+		src, err = Render(opts.Package, t)
+		if err != nil {
+			return
+		}
+		file := filepath.Join(opts.Out, fileNames[i]+".go")
+		if err = ioutil.WriteFile(file, src, 0644); err != nil {
+			return
+		}
+		files = append(files, file)
+
+		if !opts.Mapper {
+			continue
+		}
+		src, err = RenderMapper(opts.Package, []*Table{t})
+		if err != nil {
+			return
+		}
+		file = filepath.Join(opts.Out, fileNames[i]+"_dbx.go")
+		if err = ioutil.WriteFile(file, src, 0644); err != nil {
+			return
+		}
+		files = append(files, file)
+	}
+	src, err := RenderBind(opts.Package, tables)
+	if err != nil {
+		return
+	}
+	file := filepath.Join(opts.Out, "bind.go")
+	if err = ioutil.WriteFile(file, src, 0644); err != nil {
+		return
+	}
+	files = append(files, file)
+	return
+}
+
+// 生成一张表的结构体和 Repo
+func Render(pkg string, t *Table) ([]byte, error) {
+	imports := map[string]bool{"github.com/xiuno/dbx": true}
+	for _, f := range t.Fields {
+		if f.Import != "" {
+			imports[f.Import] = true
+		}
+	}
+
+	b := &bytes.Buffer{}
+	writeHeader(b, pkg, imports)
+
+	name := t.StructName
+	fmt.Fprintf(b, "const %vTable = %q\n\n", name, t.Name)
+	fmt.Fprintf(b, "// %v 对应表 %v\n", name, t.Name)
+	fmt.Fprintf(b, "type %v struct {\n", name)
+	for _, f := range t.Fields {
+		tag := f.Column
+		if f.PrimaryKey {
+			tag += ",pk"
+		}
+		if f.AutoIncrement {
+			tag += ",autoincr"
+		}
+		fmt.Fprintf(b, "\t%v %v `db:%q`\n", f.Name, f.Type, tag)
+	}
+	b.WriteString("}\n\n")
+
+	repo := name + "Repo"
+	fmt.Fprintf(b, "type %v struct {\n\tdb *dbx.DB\n}\n\n", repo)
+	fmt.Fprintf(b, "func New%v(db *dbx.DB) *%v {\n\treturn &%v{db: db}\n}\n\n", repo, repo, repo)
+	fmt.Fprintf(b, "func (r *%v) Bind(enableCache bool) {\n\tr.db.Bind(%vTable, &%v{}, enableCache)\n}\n\n", repo, name, name)
+	fmt.Fprintf(b, "func (r *%v) Table() *dbx.Query {\n\treturn r.db.Table(%vTable)\n}\n\n", repo, name)
+
+	if len(t.PK) > 0 {
+		params := make([]string, len(t.PK))
+		args := make([]string, len(t.PK))
+		for i, f := range t.PK {
+			args[i] = paramName(f.Column)
+			params[i] = args[i] + " " + f.Type
+		}
+		fmt.Fprintf(b, "func (r *%v) ByPK(%v) (*%v, error) {\n", repo, strings.Join(params, ", "), name)
+		fmt.Fprintf(b, "\trow := &%v{}\n", name)
+		fmt.Fprintf(b, "\terr := r.Table().WherePK(%v).One(row)\n", strings.Join(args, ", "))
+		b.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n\treturn row, nil\n}\n\n")
+
+		fmt.Fprintf(b, "func (r *%v) Delete(%v) (int64, error) {\n", repo, strings.Join(params, ", "))
+		fmt.Fprintf(b, "\treturn r.Table().WherePK(%v).Delete()\n}\n\n", strings.Join(args, ", "))
+	}
+
+	fmt.Fprintf(b, "func (r *%v) Find(where string, args ...interface{}) ([]*%v, error) {\n", repo, name)
+	fmt.Fprintf(b, "\tlist := []*%v{}\n", name)
+	b.WriteString("\tq := r.Table()\n\tif where != \"\" {\n\t\tq = q.Where(where, args...)\n\t}\n")
+	b.WriteString("\terr := q.All(&list)\n\treturn list, err\n}\n\n")
+
+	fmt.Fprintf(b, "func (r *%v) Insert(row *%v) (int64, error) {\n\treturn r.Table().Insert(row)\n}\n\n", repo, name)
+	fmt.Fprintf(b, "func (r *%v) Update(row *%v) (int64, error) {\n\treturn r.Table().Update(row)\n}\n", repo, name)
+
+	return formatSource(b.Bytes())
+}
+
+// 生成 Bind()，一次性绑定所有的表
+func RenderBind(pkg string, tables []*Table) ([]byte, error) {
+	b := &bytes.Buffer{}
+	writeHeader(b, pkg, map[string]bool{"github.com/xiuno/dbx": true})
+	b.WriteString("func Bind(db *dbx.DB, enableCache bool) {\n")
+	for _, t := range tables {
+		fmt.Fprintf(b, "\tdb.Bind(%vTable, &%v{}, enableCache)\n", t.StructName, t.StructName)
+	}
+	b.WriteString("}\n")
+	return formatSource(b.Bytes())
+}
+
+// 生成 dbx.Mapper 的实现，读写时不再反射
+func RenderMapper(pkg string, tables []*Table) ([]byte, error) {
+	imports := map[string]bool{"github.com/xiuno/dbx": true}
+	b := &bytes.Buffer{}
+	writeHeader(b, pkg, imports)
+
+	for _, t := range tables {
+		name := t.StructName
+		fmt.Fprintf(b, "var _ dbx.Mapper = (*%v)(nil)\n\n", name)
+
+		fmt.Fprintf(b, "func (r *%v) ScanRow(columns []string, dest []interface{}) {\n", name)
+		b.WriteString("\tfor i, col := range columns {\n\t\tswitch col {\n")
+		for _, f := range t.Fields {
+			fmt.Fprintf(b, "\t\tcase %q:\n\t\t\tdest[i] = &r.%v\n", f.Column, f.Name)
+		}
+		b.WriteString("\t\t}\n\t}\n}\n\n")
+
+		fmt.Fprintf(b, "func (r *%v) Args(columns []string) []interface{} {\n", name)
+		b.WriteString("\targs := make([]interface{}, len(columns))\n")
+		b.WriteString("\tfor i, col := range columns {\n\t\tswitch col {\n")
+		for _, f := range t.Fields {
+			fmt.Fprintf(b, "\t\tcase %q:\n\t\t\targs[i] = r.%v\n", f.Column, f.Name)
+		}
+		b.WriteString("\t\t}\n\t}\n\treturn args\n}\n\n")
+	}
+	return formatSource(b.Bytes())
+}
+
+func writeHeader(b *bytes.Buffer, pkg string, imports map[string]bool) {
+	b.WriteString("// Code generated by dbx gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %v\n\n", pkg)
+	// 标准库在前，第三方包在后
+	std, other := make([]string, 0), make([]string, 0)
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	b.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(b, "\t%q\n", path)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		b.WriteString("\n")
+	}
+	for _, path := range other {
+		fmt.Fprintf(b, "\t%q\n", path)
+	}
+	b.WriteString(")\n\n")
+}
+
+func formatSource(src []byte) ([]byte, error) {
+	out, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v\n%s", err, src)
+	}
+	return out, nil
+}
+
+// 数据库类型中的单词 -> Go 类型，按照整个单词匹配，避免 point 匹配到 int
+var goTypes = map[string]string{
+	"int": "int64", "integer": "int64", "tinyint": "int64", "smallint": "int64", "mediumint": "int64", "bigint": "int64",
+	"int2": "int64", "int4": "int64", "int8": "int64", "year": "int64",
+	"bool": "bool", "boolean": "bool",
+	"char": "string", "varchar": "string", "nchar": "string", "nvarchar": "string", "character": "string",
+	"text": "string", "tinytext": "string", "mediumtext": "string", "longtext": "string", "clob": "string",
+	"enum": "string", "set": "string", "json": "string", "time": "string",
+	"real": "float64", "float": "float64", "double": "float64", "decimal": "float64", "numeric": "float64",
+	"date": "time.Time", "datetime": "time.Time", "timestamp": "time.Time",
+	"blob": "[]byte", "tinyblob": "[]byte", "mediumblob": "[]byte", "longblob": "[]byte", "binary": "[]byte",
+	"varbinary": "[]byte", "bit": "[]byte", "geometry": "[]byte", "point": "[]byte", "linestring": "[]byte",
+	"polygon": "[]byte", "multipoint": "[]byte", "multilinestring": "[]byte", "multipolygon": "[]byte",
+	"geometrycollection": "[]byte", "geomcollection": "[]byte",
+}
+
+// 数据库类型 -> Go 类型，可以为 NULL 的列使用 sql.NullXxx，bigint unsigned 使用 *uint64
+func GoType(driverType int, col *dbx.TableColumn) (typ string, imp string) {
+	if driverType == dbx.DRIVER_CQL {
+		return cqlGoType(col.Type)
+	}
+	// unsigned big int -> [unsigned big int]
+	words := strings.FieldsFunc(col.Type, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	typ = "string"
+	if len(words) == 0 {
+		typ = "[]byte"
+	}
+	for _, w := range words {
+		if typ2, ok := goTypes[w]; ok {
+			typ = typ2
+			break
+		}
+	}
+	if typ == "int64" && col.Unsigned && hasWord("bigint", words) {
+		typ = "uint64"
+	}
+	if typ == "time.Time" {
+		imp = "time"
+	}
+	if !col.Nullable {
+		return
+	}
+	switch typ {
+	case "int64":
+		typ, imp = "sql.NullInt64", "database/sql"
+	case "uint64":
+		typ = "*uint64"
+	case "bool":
+		typ, imp = "sql.NullBool", "database/sql"
+	case "string":
+		typ, imp = "sql.NullString", "database/sql"
+	case "float64":
+		typ, imp = "sql.NullFloat64", "database/sql"
+	case "time.Time":
+		typ, imp = "sql.NullTime", "database/sql"
+	}
+	return
+}
+
+func hasWord(w string, words []string) bool {
+	for _, w2 := range words {
+		if w2 == w {
+			return true
+		}
+	}
+	return false
+}
+
+func cqlGoType(t string) (typ string, imp string) {
+	switch t {
+	case "int":
+		typ = "int"
+	case "bigint", "counter", "varint":
+		typ = "int64"
+	case "smallint":
+		typ = "int16"
+	case "tinyint":
+		typ = "int8"
+	case "boolean":
+		typ = "bool"
+	case "float":
+		typ = "float32"
+	case "double":
+		typ = "float64"
+	case "decimal":
+		typ, imp = "*inf.Dec", "gopkg.in/inf.v0"
+	case "timestamp", "date":
+		typ, imp = "time.Time", "time"
+	case "duration":
+		typ, imp = "gocql.Duration", "github.com/gocql/gocql"
+	case "uuid", "timeuuid":
+		typ, imp = "gocql.UUID", "github.com/gocql/gocql"
+	case "blob":
+		typ = "[]byte"
+	default:
+		typ = "string"
+	}
+	return
+}
+
+// user_group / userGroup -> UserGroup，字母、数字以外的字符作为分隔符
+func CamelCase(name string) string {
+	b := strings.Builder{}
+	upper := true
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if upper {
+			b.WriteRune(unicode.ToUpper(c))
+			upper = false
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "F" + s
+	}
+	return s
+}
+
+// 表名 -> 结构体名，避免与 bind.go 中的 Bind() 重名
+func StructName(table string) string {
+	s := CamelCase(table)
+	if s == "Bind" {
+		s += "Model"
+	}
+	return s
+}
+
+// 列名 -> 字段名，避免与 dbx.Mapper 的方法重名
+func FieldName(col string) string {
+	s := CamelCase(col)
+	switch s {
+	case "ScanRow", "Args":
+		s += "Field"
+	}
+	return s
+}
+
+// go build 按照文件名的后缀判断是否编译：_test、_linux、_amd64 等
+var fileSuffixes = map[string]bool{
+	"test": true, "dbx": true,
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true, "illumos": true, "ios": true,
+	"js": true, "linux": true, "nacl": true, "netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+	"windows": true, "zos": true,
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+	"mips": true, "mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true, "mips64p32le": true, "ppc": true,
+	"ppc64": true, "ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true, "sparc": true, "sparc64": true,
+	"wasm": true,
+}
+
+// 表名 -> 文件名（不含 .go）：小写，字母、数字以外的字符替换为 _；
+// 以 _test、_linux、_amd64、_dbx 结尾或者为 bind 时加上 _table，避免被 go build 忽略、限制平台或者与生成的其他文件重名
+func FileName(table string) string {
+	b := strings.Builder{}
+	for _, c := range strings.ToLower(table) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	// _ 开头的文件也会被忽略
+	s := strings.TrimLeft(b.String(), "_")
+	if s == "" {
+		s = "table"
+	}
+	if i := strings.LastIndex(s, "_"); s == "bind" || i > 0 && fileSuffixes[s[i+1:]] {
+		s += "_table"
+	}
+	return s
+}
+
+// 目录名 -> 合法的包名
+func PackageName(dir string) string {
+	b := strings.Builder{}
+	for _, c := range strings.ToLower(dir) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+		}
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "models"
+	}
+	return s
+}
+
+// create_date -> createDate，避免与关键字冲突
+func paramName(col string) string {
+	s := CamelCase(col)
+	s = strings.ToLower(s[0:1]) + s[1:]
+	switch s {
+	case "type", "func", "var", "range", "map", "default", "select", "case", "go", "chan", "package", "import", "interface", "struct", "const", "return", "break", "continue", "for", "if", "else", "switch", "goto", "defer", "fallthrough", "row", "r", "err":
+		s += "_"
+	}
+	return s
+}
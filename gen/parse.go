@@ -0,0 +1,149 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 从 Go 源文件中读取带 db tag 的结构体，供 RenderMapper() 使用。
+// 匿名嵌套的结构体必须定义在同一个文件里。
+func ParseFile(file string, types []string) (pkg string, tables []*Table, err error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		return
+	}
+	pkg = f.Name.Name
+
+	structs := map[string]*ast.StructType{}
+	names := make([]string, 0)
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			structs[ts.Name.Name] = st
+			names = append(names, ts.Name.Name)
+		}
+	}
+	if len(types) == 0 {
+		types = names
+	}
+
+	for _, name := range types {
+		st, ok := structs[name]
+		if !ok {
+			return "", nil, fmt.Errorf("struct %v not found in %v", name, file)
+		}
+		t := &Table{StructName: name}
+		if err = parseFields(t, structs, st, "", ""); err != nil {
+			return "", nil, fmt.Errorf("struct %v: %v", name, err)
+		}
+		if len(t.Fields) == 0 {
+			// 没有 db tag 的结构体不是表
+			if len(types) == len(names) {
+				continue
+			}
+			return "", nil, fmt.Errorf("struct %v has no db tag", name)
+		}
+		tables = append(tables, t)
+	}
+	return
+}
+
+// prefix 为嵌套的字段路径，colPrefix 为嵌套的 db tag 的列名前缀，与 dbx 相同：User `db:"u"` 的列为 u.uid
+func parseFields(t *Table, structs map[string]*ast.StructType, st *ast.StructType, prefix string, colPrefix string) error {
+	for _, field := range st.Fields.List {
+		tag := ""
+		if field.Tag != nil {
+			tag, _ = strconv.Unquote(field.Tag.Value)
+		}
+		dbTag, hasTag := reflect.StructTag(tag).Lookup("db")
+		colName, opts := dbTag, ""
+		if n := strings.Index(dbTag, ","); n != -1 {
+			colName, opts = dbTag[:n], dbTag[n+1:]
+		}
+
+		// 匿名嵌套
+		if len(field.Names) == 0 {
+			if colName == "-" {
+				continue
+			}
+			ident, ok := field.Type.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("embedded field %v is not supported, only struct defined in the same file", exprString(field.Type))
+			}
+			sub, ok := structs[ident.Name]
+			if !ok {
+				return fmt.Errorf("embedded struct %v is not defined in the same file", ident.Name)
+			}
+			colPrefix2 := colPrefix
+			if colName != "" {
+				colPrefix2 = colPrefix + colName + "."
+			}
+			if err := parseFields(t, structs, sub, prefix+ident.Name+".", colPrefix2); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasTag || colName == "" || colName == "-" {
+			continue
+		}
+		f := &Field{Column: colPrefix + colName, Type: exprString(field.Type)}
+		// default 可以包含逗号，总是最后一个选项
+		if n := strings.Index(opts, "default="); n != -1 {
+			opts = opts[:n]
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "pk":
+				f.PrimaryKey = true
+			case "autoincr":
+				f.AutoIncrement = true
+			case "json":
+				return fmt.Errorf("column %v: json column is not supported by mapper", colName)
+			}
+		}
+		for _, n := range field.Names {
+			if !n.IsExported() {
+				continue
+			}
+			field := *f
+			field.Name = prefix + n.Name
+			t.Fields = append(t.Fields, &field)
+			if field.PrimaryKey {
+				t.PK = append(t.PK, t.Fields[len(t.Fields)-1])
+			}
+		}
+	}
+	return nil
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + exprString(e.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(e.Key) + "]" + exprString(e.Value)
+	case *ast.InterfaceType:
+		return "interface{}"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%T", expr))
+}
@@ -0,0 +1,197 @@
+package dbx
+
+import (
+	"context"
+	"reflect"
+)
+
+// 泛型查询，结果的类型在编译期检查：
+//
+//	u, err := dbx.T[User](db, "user").WherePK(1).One()
+//	list, err := dbx.T[User](db, "user").Where("gid=?", 1).All()
+//
+// 与 db.Table() 共用 Bind() 注册的 TableStruct 以及缓存。
+type TQuery[E any] struct {
+	q *Query
+}
+
+// E 必须为 struct
+func T[E any](db *DB, tableName string) *TQuery[E] {
+	t := reflect.TypeOf((*E)(nil))
+	if t.Elem().Kind() != reflect.Struct {
+		panic(dbxErrorNew("dbx.T[E]: E must be a struct: %v", t.Elem()))
+	}
+	return &TQuery[E]{q: db.Table(tableName)}
+}
+
+// 没有 Bind() 时按照 E 注册表结构
+func (t *TQuery[E]) tableStruct() (tableStruct *TableStruct, err error) {
+	defer dbxErrorDefer(&err, t.q)
+	tableStruct = t.q.getTableStruct(reflect.TypeOf((*E)(nil)))
+	return
+}
+
+// 底层的 *Query，用于泛型层没有覆盖到的方法
+func (t *TQuery[E]) Query() *Query {
+	return t.q
+}
+
+func (t *TQuery[E]) Fields(fields ...interface{}) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Fields(fields...)}
+}
+
+func (t *TQuery[E]) Where(str string, args ...interface{}) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Where(str, args...)}
+}
+
+func (t *TQuery[E]) And(str string, args ...interface{}) *TQuery[E] {
+	return &TQuery[E]{q: t.q.And(str, args...)}
+}
+
+func (t *TQuery[E]) Or(str string, args ...interface{}) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Or(str, args...)}
+}
+
+func (t *TQuery[E]) WhereM(m M) *TQuery[E] {
+	return &TQuery[E]{q: t.q.WhereM(m)}
+}
+
+func (t *TQuery[E]) WherePK(args ...interface{}) *TQuery[E] {
+	return &TQuery[E]{q: t.q.WherePK(args...)}
+}
+
+func (t *TQuery[E]) Sort(colName interface{}, order int, nulls ...int) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Sort(colName, order, nulls...)}
+}
+
+// 用户输入的排序，只接受 allowed 中的列
+func (t *TQuery[E]) SortBy(spec string, allowed ...string) *TQuery[E] {
+	return &TQuery[E]{q: t.q.SortBy(spec, allowed...)}
+}
+
+func (t *TQuery[E]) SortM(m M) *TQuery[E] {
+	return &TQuery[E]{q: t.q.SortM(m)}
+}
+
+func (t *TQuery[E]) Limit(limitStart int64, limitEnds ...int64) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Limit(limitStart, limitEnds...)}
+}
+
+// 应用 Scope() 注册的条件
+func (t *TQuery[E]) Scopes(names ...string) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Scopes(names...)}
+}
+
+func (t *TQuery[E]) Clone() *TQuery[E] {
+	return &TQuery[E]{q: t.q.Clone()}
+}
+
+func (t *TQuery[E]) Preload(fields ...string) *TQuery[E] {
+	return &TQuery[E]{q: t.q.Preload(fields...)}
+}
+
+// 没有数据时返回 nil, ErrNoRows
+func (t *TQuery[E]) One() (*E, error) {
+	row := new(E)
+	if err := t.q.One(row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// 没有数据时返回空的 slice，不返回 ErrNoRows
+func (t *TQuery[E]) All() ([]E, error) {
+	list := make([]E, 0)
+	err := t.q.All(&list)
+	if err == ErrNoRows {
+		err = nil
+	}
+	return list, err
+}
+
+// 按照主键排序后的第一条（未指定排序时）
+func (t *TQuery[E]) First() (*E, error) {
+	q := t.q
+	if len(q.orderBy) == 0 {
+		tableStruct, err := t.tableStruct()
+		if err != nil {
+			return nil, err
+		}
+		for _, colName := range tableStruct.PrimaryKey {
+			q = q.Sort(colName, 1)
+		}
+	}
+	return (&TQuery[E]{q: q}).One()
+}
+
+func (t *TQuery[E]) ByPK(keys ...interface{}) (*E, error) {
+	return t.WherePK(keys...).One()
+}
+
+// 逐行回调，不会把结果全部放到内存中，fn 返回 dbx.ErrStop 时提前结束
+func (t *TQuery[E]) Iterate(ctx context.Context, fn func(row *E) error) error {
+	return t.q.Iterate(ctx, fn)
+}
+
+// 按照主键分批读取
+func (t *TQuery[E]) Chunk(ctx context.Context, size int, fn func(list []E) error) error {
+	return t.q.Chunk(ctx, size, fn)
+}
+
+// 游标翻页，nextCursor 为空时没有下一页
+func (t *TQuery[E]) Page(cursor string, size int) (items []E, nextCursor string, err error) {
+	items = make([]E, 0)
+	nextCursor, err = t.q.Page(cursor, size, &items)
+	return
+}
+
+// 按页码分页，同时返回总行数
+func (t *TQuery[E]) Paginate(page int, perPage int) (items []E, p Pagination, err error) {
+	items = make([]E, 0)
+	p, err = t.q.Paginate(page, perPage, &items)
+	return
+}
+
+func (t *TQuery[E]) Count() (int64, error) {
+	return t.q.Count()
+}
+
+func (t *TQuery[E]) Insert(row *E) (insertId int64, err error) {
+	return t.q.Insert(row)
+}
+
+// 批量插入，返回自增 ID
+func (t *TQuery[E]) InsertBatch(rows []*E, opts ...BatchOpts) (ids []int64, err error) {
+	return t.q.InsertBatch(rows, opts...)
+}
+
+// 冲突时更新 c 中的列，插入新行时 inserted 为 true
+func (t *TQuery[E]) Upsert(row *E, c *Conflict) (inserted bool, err error) {
+	return t.q.Upsert(row, c)
+}
+
+func (t *TQuery[E]) Update(row *E) (affectedRows int64, err error) {
+	return t.q.Update(row)
+}
+
+func (t *TQuery[E]) UpdateM(m M) (affectedRows int64, err error) {
+	return t.q.UpdateM(m)
+}
+
+// 按照主键批量更新
+func (t *TQuery[E]) UpdateBatch(rows []*E, opts ...BatchOpts) (affectedRows int64, err error) {
+	return t.q.UpdateBatch(rows, opts...)
+}
+
+// 按照主键批量删除
+func (t *TQuery[E]) DeleteByPKs(keys ...interface{}) (n int64, err error) {
+	if _, err = t.tableStruct(); err != nil {
+		return
+	}
+	return t.q.DeleteByPKs(keys...)
+}
+
+// 按照当前的条件删除，例如 T[User](db, "user").WherePK(1).Delete()
+func (t *TQuery[E]) Delete() (n int64, err error) {
+	return t.q.Delete()
+}
@@ -2,8 +2,10 @@ package dbx
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -15,7 +17,7 @@ import (
 )
 
 func sqlite_get_create_table_sql(db *DB, tableName string) (string) {
-	row := db.QueryRow(`select sql from sqlite_master where type="table" and name="` + tableName + `"`)
+	row := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name=?`, tableName)
 
 	var str string
 	err := row.Scan(&str)
@@ -26,7 +28,7 @@ func sqlite_get_create_table_sql(db *DB, tableName string) (string) {
 }
 
 func mysql_get_create_table_sql(db *DB, tableName string) (string) {
-	row := db.QueryRow("show create table `" + tableName + "`")
+	row := db.QueryRow("SHOW CREATE TABLE " + quote_ident(tableName, false))
 
 	var tableName1, createTable string
 	err := row.Scan(&tableName1, &createTable)
@@ -155,8 +157,26 @@ func get_table_info(db *DB, talbeName string) (pk []string, auto_increment strin
 	}
 }
 
-// t 兼容 struct / &struct
-func struct_fields_range_do(colFieldMap *ColFieldMap, t2 reflect.Type, pos1 []int) {
+// Cassandra 表的分区键和聚簇列，表不存在时为空
+func cql_get_keys(db *DB, talbeName string) (partition []string, clustering []string) {
+	if db.CQLMeta == nil {
+		return
+	}
+	table, ok := db.CQLMeta.Tables[talbeName]
+	if !ok {
+		return
+	}
+	for _, v := range table.PartitionKey {
+		partition = append(partition, v.Name)
+	}
+	for _, v := range table.ClusteringColumns {
+		clustering = append(clustering, v.Name)
+	}
+	return
+}
+
+// t 兼容 struct / &struct，prefix 为嵌套的匿名字段的 db tag（表的别名），列名为 "别名.列名"
+func struct_fields_range_do(colFieldMap *ColFieldMap, t2 reflect.Type, pos1 []int, naming NamingStrategy, prefix string) {
 	t := t2
 	if t.Kind() != reflect.Struct {
 		if t.Kind() == reflect.Ptr {
@@ -180,17 +200,42 @@ func struct_fields_range_do(colFieldMap *ColFieldMap, t2 reflect.Type, pos1 []in
 			fieldType = fieldType.Elem()
 		}
 		tagName, ok := field.Tag.Lookup("db")
-		col.ColName = tagName
-		col.FieldName = field.Name
+		if tagName == "-" {
+			continue
+		}
+		parse_db_tag(col, tagName)
+		col.FieldName = prefix + field.Name
 		col.FieldPos = pos2
 		col.FieldStruct = field
 
+		// 关联的字段不对应列：dbx:"hasmany:post,fk=uid"
+		if relTag, ok2 := field.Tag.Lookup("dbx"); ok2 {
+			col.Relation = parse_relation_tag(relTag)
+			col.ColName = ""
+			colFieldMap.Add(col)
+			continue
+		}
+
+		// 没有指定列名时按照命名策略生成，不导出的字段除外
+		if col.ColName == "" && naming != nil && !field.Anonymous && field.PkgPath == "" {
+			col.ColName = naming(field.Name)
+		}
+
+		if col.ColName != "" && prefix != "" && !field.Anonymous {
+			col.ColName = prefix + col.ColName
+		}
+
 		if !ok && field.Anonymous == false {
 			colFieldMap.Add(col)
 			continue
 		}
 		if field.Anonymous == true {
-			struct_fields_range_do(colFieldMap, fieldType, pos2)
+			// User `db:"u"`：嵌套的列为 u.uid, u.name ...
+			prefix2 := prefix
+			if col.ColName != "" {
+				prefix2 = prefix + col.ColName + "."
+			}
+			struct_fields_range_do(colFieldMap, fieldType, pos2, naming, prefix2)
 		} else {
 			colFieldMap.Add(col)
 		}
@@ -198,6 +243,49 @@ func struct_fields_range_do(colFieldMap *ColFieldMap, t2 reflect.Type, pos1 []in
 	return
 }
 
+/*
+	db:"uid,pk"           主键，覆盖表结构的检测结果
+	db:"id,autoincr"      自增列，覆盖表结构的检测结果
+	db:"created,readonly" 只读，Insert / Update / Replace 不写入
+	db:"note,omitempty"   Insert 时零值不写入，使用数据库的默认值
+	db:"meta,json"        序列化为 JSON 存储
+	db:"t,time=utc|ms"    time.Time 的存储格式、时区和精度，见 TimePolicy
+	db:"x,default=abc"    Insert 时零值使用该默认值，必须为最后一个选项
+*/
+func parse_db_tag(col *Col, tag string) {
+	arr := strings.Split(tag, ",")
+	col.ColName = strings.TrimSpace(arr[0])
+	for i := 1; i < len(arr); i++ {
+		opt := strings.TrimSpace(arr[i])
+		switch {
+		case opt == "pk":
+			col.PrimaryKey = true
+		case opt == "autoincr":
+			col.AutoIncrement = true
+		case opt == "readonly":
+			col.ReadOnly = true
+		case opt == "omitempty":
+			col.OmitEmpty = true
+		case opt == "json":
+			col.JSON = true
+		case strings.HasPrefix(opt, "time="):
+			p, err := parse_time_tag(opt[len("time="):])
+			if err != nil {
+				panic(err)
+			}
+			col.timePolicy = p
+		case strings.HasPrefix(opt, "default="):
+			// 默认值中可能有逗号
+			col.Default = strings.TrimLeft(strings.Join(arr[i:], ","), " ")[len("default="):]
+			col.HasDefault = true
+			return
+		case opt == "":
+		default:
+			panic(dbxErrorNew("unknown db tag option: %v, tag: %v", opt, tag))
+		}
+	}
+}
+
 //func struct_field_type_get(p reflect.Type, pos []int) reflect.Type {
 //	var p2 reflect.Type
 //	p2 = p
@@ -325,13 +413,50 @@ func cql_columns(arr []gocql.ColumnInfo) []string {
 	return ret
 }
 
+// 与 get_reflect_value_from_pos() 相同，但是不解引用最后一层的指针字段，用于写入 NULL
+func get_reflect_field_from_pos(v reflect.Value, pos []int) reflect.Value {
+	if len(pos) == 0 {
+		return v
+	}
+	parent := get_reflect_value_from_pos(v, pos[:len(pos)-1])
+	return parent.Field(pos[len(pos)-1])
+}
+
+// 读取字段的值用于写入数据库，不会分配 nil 指针，遇到 nil 指针返回 nil（NULL）
+func get_value_from_pos(v reflect.Value, pos []int) interface{} {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	for _, i := range pos {
+		v = v.Field(i)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+	}
+	return v.Interface()
+}
+
+func is_zero(vi interface{}) bool {
+	return vi == nil || reflect.ValueOf(vi).IsZero()
+}
+
 // 第2个参数约定为：struct, 不能为 &struct
 func get_pk_keys(tableStruct *TableStruct, row reflect.Value) string {
+	if m := row_to_mapper(tableStruct, row); m != nil {
+		keys := m.Args(tableStruct.PrimaryKey)
+		for i, v := range keys {
+			keys[i] = pk_key_value(tableStruct, i, v)
+		}
+		return get_key_str_by_args(keys...)
+	}
 	pkKeyName := make([]interface{}, 0)
 	pkStr := ""
-	for _, pos := range tableStruct.PrimaryKeyPos {
+	for i, pos := range tableStruct.PrimaryKeyPos {
 		row2 := get_reflect_value_from_pos(row, pos)
-		pkKeyName = append(pkKeyName, row2.Interface())
+		pkKeyName = append(pkKeyName, pk_key_value(tableStruct, i, row2.Interface()))
 		pkStr += "%v-"
 	}
 	pkStr = strings.TrimRight(pkStr, "-")
@@ -348,13 +473,13 @@ func get_pk_values(tableStruct *TableStruct, value reflect.Value, isCQL bool) ([
 			continue
 		}
 		n := tableStruct.ColFieldMap.colMap[colName]
-		pos := tableStruct.ColFieldMap.cols[n].FieldPos
-		v := get_reflect_value_from_pos(value, pos)
-		vi := v.Interface()
+		col := tableStruct.ColFieldMap.cols[n]
+		v := get_reflect_value_from_pos(value, col.FieldPos)
+		vi := value_to_arg(v.Interface(), isCQL)
 		vtime, ok := vi.(time.Time)
 		var tmp interface{}
-		if ok && !isCQL {
-			tmp = vtime.Format("2006-01-02 15:04:05")
+		if ok {
+			tmp = tableStruct.time_policy(col).to_db(vtime, isCQL)
 		} else {
 			tmp = vi
 		}
@@ -368,7 +493,8 @@ func arr_to_sql_add(arr []string, sep1 string, sep2 string, isCQL bool) string {
 	sqlAdd := ""
 	if !isCQL {
 		for _, v := range arr {
-			sqlAdd += fmt.Sprintf("`%v`%v%v", v, sep1, sep2)
+			// 带表名或别名的列：`u`.`uid`
+			sqlAdd += fmt.Sprintf("`%v`%v%v", strings.Replace(v, ".", "`.`", -1), sep1, sep2)
 		}
 	} else {
 		for _, v := range arr {
@@ -379,7 +505,8 @@ func arr_to_sql_add(arr []string, sep1 string, sep2 string, isCQL bool) string {
 	return sqlAdd
 }
 
-func arr_to_sql_add_update(arr []string, opcodes []string, isCQL bool) string {
+func arr_to_sql_add_update(arr []string, opcodes []string, driverType int, tableStruct *TableStruct) string {
+	isCQL := driverType == DRIVER_CQL
 	sqlAdd := ""
 	opcode := ""
 	for k, v := range arr {
@@ -390,9 +517,31 @@ func arr_to_sql_add_update(arr []string, opcodes []string, isCQL bool) string {
 		}
 		// opcode == "" ||
 		if !isCQL {
-			if opcode == "=" {
+			switch opcode {
+			case "=":
 				sqlAdd += fmt.Sprintf("`%v`=?,", v)
-			} else {
+			case "<", ">":
+				// 与缓存中的计算一致：LEAST / GREATEST
+				fn := map[string]string{"<": "LEAST", ">": "GREATEST"}[opcode]
+				if driverType == DRIVER_SQLITE {
+					fn = map[string]string{"<": "MIN", ">": "MAX"}[opcode]
+				}
+				sqlAdd += fmt.Sprintf("`%v`=%v(`%v`,?),", v, fn, v)
+			case "/":
+				// 整数列为整除，MySQL 的 / 返回小数
+				if driverType == DRIVER_MYSQL && is_int_col(tableStruct, v) {
+					sqlAdd += fmt.Sprintf("`%v`=`%v` DIV ?,", v, v)
+				} else {
+					sqlAdd += fmt.Sprintf("`%v`=`%v`/?,", v, v)
+				}
+			case "%":
+				// SQLite 的 % 先把 REAL 转换为整数，与缓存中的 math.Mod() 不同：a - b * trunc(a / b)
+				if driverType == DRIVER_SQLITE && is_float_col(tableStruct, v) {
+					sqlAdd += fmt.Sprintf("`%v`=(SELECT `%v`-dbx_b*CAST(`%v`/dbx_b AS INTEGER) FROM (SELECT ? AS dbx_b)),", v, v, v)
+				} else {
+					sqlAdd += fmt.Sprintf("`%v`=`%v`%%?,", v, v)
+				}
+			default:
 				sqlAdd += fmt.Sprintf("`%v`=`%v`%v?,", v, v, opcode)
 			}
 		} else {
@@ -407,6 +556,35 @@ func arr_to_sql_add_update(arr []string, opcodes []string, isCQL bool) string {
 	return sqlAdd
 }
 
+func is_int_col(tableStruct *TableStruct, colName string) bool {
+	switch col_kind(tableStruct, colName) {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func is_float_col(tableStruct *TableStruct, colName string) bool {
+	k := col_kind(tableStruct, colName)
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// 列对应的字段的类型，指针为指向的类型
+func col_kind(tableStruct *TableStruct, colName string) reflect.Kind {
+	if tableStruct == nil {
+		return reflect.Invalid
+	}
+	col := tableStruct.ColFieldMap.GetByColName(colName)
+	if col == nil {
+		return reflect.Invalid
+	}
+	t := col.FieldStruct.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind()
+}
+
 // 差异太大，直接拷贝省事
 //type RowsIfc interface {
 //	Columns() ([]string, error)
@@ -431,7 +609,7 @@ func rows_to_arr_list(destp reflect.Value, rows *sql.Rows, tableStruct *TableStr
 	if err != nil {
 		return
 	}
-	posMap := map[int][]int{}
+	posMap := map[int]*Col{}
 	for k, colName := range columns {
 		n, ok := tableStruct.ColFieldMap.colMap[colName]
 		if !ok {
@@ -439,7 +617,7 @@ func rows_to_arr_list(destp reflect.Value, rows *sql.Rows, tableStruct *TableStr
 			continue
 		}
 		col := tableStruct.ColFieldMap.cols[n]
-		posMap[k] = col.FieldPos
+		posMap[k] = col
 	}
 
 	values := make([]interface{}, len(columns))
@@ -447,24 +625,44 @@ func rows_to_arr_list(destp reflect.Value, rows *sql.Rows, tableStruct *TableStr
 		// 每一列对应的类型
 		values[i] = new(interface{})
 	}
+	holders := values
+	isMapper := tableStruct.is_mapper()
+	if isMapper {
+		values = make([]interface{}, len(columns))
+	}
 
 	totalRows := 0
 	for rows.Next() {
+		row := reflect.New(tableStruct.Type.Elem())
+		rowElem := row.Elem()
+		if isMapper {
+			// 生成的代码直接给出字段的指针，跳过反射
+			mapper_scan_dest(row.Interface().(Mapper), columns, values, holders)
+			err = rows.Scan(values...)
+			if err != nil {
+				return
+			}
+			if arrIsPtr {
+				dest = reflect.Append(dest, row)
+			} else {
+				dest = reflect.Append(dest, rowElem)
+			}
+			totalRows++
+			continue
+		}
 		err = rows.Scan(values...)
 		if err != nil {
 			return
 		}
 		// 对应到相应的列
-		row := reflect.New(tableStruct.Type.Elem())
-		rowElem := row.Elem()
 		for k, _ := range columns {
-			pos, ok := posMap[k]
+			col, ok := posMap[k]
 			// 如果不存在，则跳过
 			if !ok {
 				continue
 			}
 
-			ifc_pos_to_value(values[k], pos, row)
+			ifc_pos_to_value(tableStruct, values[k], col, row)
 
 		}
 		if arrIsPtr {
@@ -519,10 +717,11 @@ func cql_rows_to_arr_list(destp *reflect.Value, rows *gocql.Iter, tableStruct *T
 	}
 
 	// 数据库返回的列，需要和表结构进行对应
-	columns := cql_columns(rows.Columns())
+	infos := rows.Columns()
+	columns := cql_columns(infos)
 	values := make([]interface{}, len(columns))
 
-	posMap := map[int][]int{}
+	posMap := map[int]*Col{}
 	for k, colName := range columns {
 		n, ok := tableStruct.ColFieldMap.colMap[colName]
 		if !ok {
@@ -530,25 +729,44 @@ func cql_rows_to_arr_list(destp *reflect.Value, rows *gocql.Iter, tableStruct *T
 			continue
 		}
 		col := tableStruct.ColFieldMap.cols[n]
-		posMap[k] = col.FieldPos
-		values[k] = reflect.New(col.FieldStruct.Type).Interface()
+		posMap[k] = col
+		values[k] = cql_scan_dest(col, infos[k])
 	}
 
 	totalRows := 0
-	for rows.Scan(values...) {
+	holders := make([]interface{}, len(columns))
+	isMapper := tableStruct.is_mapper()
+	for {
+		row := reflect.New(tableStruct.Type.Elem())
+		rowElem := row.Elem()
+		if isMapper {
+			// 生成的代码直接给出字段的指针，跳过反射
+			mapper_scan_dest(row.Interface().(Mapper), columns, values, holders)
+			if !rows.Scan(values...) {
+				break
+			}
+			if arrIsPtr {
+				dest = reflect.Append(dest, row)
+			} else {
+				dest = reflect.Append(dest, rowElem)
+			}
+			totalRows++
+			continue
+		}
+		if !rows.Scan(values...) {
+			break
+		}
 
 		//fmt.Printf("values: %v\n", values)
 		// 对应到相应的列
-		row := reflect.New(tableStruct.Type.Elem())
-		rowElem := row.Elem()
 		for k, _ := range columns {
-			pos, ok := posMap[k]
+			col, ok := posMap[k]
 			// 如果不存在，则跳过
 			if !ok {
 				continue
 			}
 
-			ifc_pos_to_value(values[k], pos, row)
+			ifc_pos_to_value(tableStruct, values[k], col, row)
 
 			//value2 := reflect.ValueOf(values[k]).Elem()
 			//col := get_reflect_value_from_pos(value2, fromPos)
@@ -577,24 +795,50 @@ func cql_rows_to_arr_list(destp *reflect.Value, rows *gocql.Iter, tableStruct *T
 	return
 }
 
-// uncludePK 是否排除主键
-func struct_value_to_args(tableStruct *TableStruct, value reflect.Value, uncludeAutoIncrement bool, uncludePK bool, isCQL bool) ([]interface{}, []interface{}, interface{}) {
-	args := make([]interface{}, 0)
-	pkArgs := make([]interface{}, 0)
-	var autoIncrementArg interface{}
-	for _, colName := range tableStruct.ColFieldMap.colArr {
+// 返回需要写入的列和对应的值，uncludePK 是否排除主键；readonly 的列从不写入。
+// isInsert 时处理 omitempty / default，default 会回写到结构体，保证缓存与数据库一致。
+func struct_value_to_args(tableStruct *TableStruct, value reflect.Value, uncludeAutoIncrement bool, uncludePK bool, isCQL bool, isInsert bool) (colNames []string, args []interface{}, pkArgs []interface{}, autoIncrementArg interface{}) {
+	colNames = make([]string, 0)
+	args = make([]interface{}, 0)
+	pkArgs = make([]interface{}, 0)
+	var mapperArgs []interface{}
+	if m := row_to_mapper(tableStruct, value); m != nil {
+		mapperArgs = m.Args(tableStruct.ColFieldMap.colArr)
+	}
+	for i, colName := range tableStruct.ColFieldMap.colArr {
 
 		flagIncrement := (colName == tableStruct.AutoIncrement)
 		flagPK := (in_array(colName, tableStruct.PrimaryKey))
 
-		n := tableStruct.ColFieldMap.colMap[colName]
-		pos := tableStruct.ColFieldMap.cols[n].FieldPos
-		v := get_reflect_value_from_pos(value, pos)
-		vi := v.Interface()
-		vtime, ok := vi.(time.Time)
+		col := tableStruct.ColFieldMap.GetByColName(colName)
+		var vi interface{}
+		if mapperArgs != nil {
+			vi = mapperArgs[i]
+		} else {
+			vi = get_value_from_pos(value, col.FieldPos)
+		}
+		if isInsert && col.HasDefault && is_zero(vi) {
+			v := get_reflect_value_from_pos(value, col.FieldPos)
+			dv, err := str_to_value(col.Default, v.Type(), col.JSON)
+			if err != nil {
+				panic(dbxErrorNew("default value of %v: %v", colName, err.Error()))
+			}
+			v.Set(dv)
+			vi = dv.Interface()
+		}
+		if isInsert && col.OmitEmpty && is_zero(vi) {
+			continue
+		}
 		var tmp interface{}
-		if ok && !isCQL {
-			tmp = vtime.Format("2006-01-02 15:04:05")
+		if !col.JSON {
+			vi = value_to_arg(vi, isCQL)
+		}
+		if vi == nil {
+			tmp = nil // NULL
+		} else if col.JSON {
+			tmp = json_marshal(vi)
+		} else if vtime, ok := vi.(time.Time); ok {
+			tmp = tableStruct.time_policy(col).to_db(vtime, isCQL)
 		} else {
 			tmp = vi
 		}
@@ -604,28 +848,185 @@ func struct_value_to_args(tableStruct *TableStruct, value reflect.Value, unclude
 		if flagPK {
 			pkArgs = append(pkArgs, tmp)
 		}
-		if uncludeAutoIncrement && flagIncrement || uncludePK && flagPK {
+		if uncludeAutoIncrement && flagIncrement || uncludePK && flagPK || col.ReadOnly {
 			continue
 		}
+		colNames = append(colNames, colName)
 		args = append(args, tmp)
 	}
-	return args, pkArgs, autoIncrementArg
+	return
 }
 
-func ifc_pos_to_value(fromIfc interface{}, fromPos []int, retValue reflect.Value) error {
+func ifc_pos_to_value(tableStruct *TableStruct, fromIfc interface{}, fromCol *Col, retValue reflect.Value) error {
 	value := reflect.ValueOf(fromIfc).Elem().Interface() // 兼容性良好一些
 	//value := *(fromIfc.(*interface{})) // db 里面取出来的数据，废弃的写法
 
 	//valueV := reflect.ValueOf(value)
 	//valueKind := valueV.Kind()
 
-	col := get_reflect_value_from_pos(retValue.Elem(), fromPos)
+	col := get_reflect_field_from_pos(retValue.Elem(), fromCol.FieldPos)
 
-	set_value_to_ifc(col, value)
+	set_col_value(tableStruct, fromCol, col, value)
 
 	return nil
 }
 
+// 按照列的选项赋值，dv 为字段本身（指针字段不解引用）：
+// NULL 写入指针字段为 nil，sql.Scanner 调用 Scan(nil)，其他类型 DB.NullToZero 时赋零值，否则报错；
+// json 列需要反序列化，time.Time 按照 TimePolicy 解析。
+func set_col_value(tableStruct *TableStruct, col *Col, dv reflect.Value, src interface{}) {
+	// CQL Scan 到指针类型，NULL 为 nil 指针
+	sv := reflect.ValueOf(src)
+	for sv.Kind() == reflect.Ptr {
+		if sv.IsNil() {
+			src = nil
+			break
+		}
+		sv = sv.Elem()
+		src = sv.Interface()
+	}
+	if src == nil {
+		set_null_value(col, dv, tableStruct.nullToZero())
+		return
+	}
+	if dv.Kind() == reflect.Ptr {
+		if dv.IsNil() {
+			dv.Set(reflect.New(dv.Type().Elem()))
+		}
+		dv = dv.Elem()
+	}
+	if col.JSON {
+		// 从数据库读出的 JSON 字符串；UpdateM() 更新缓存时为原值
+		switch src.(type) {
+		case string, []byte:
+			json_unmarshal(dv, src)
+			return
+		}
+	}
+	if dv.Type() == timeType {
+		if tm, ok := tableStruct.time_policy(col).from_db(src, tableStruct.isCQL()); ok {
+			dv.Set(reflect.ValueOf(tm))
+			return
+		}
+	}
+	if reflect.TypeOf(src) == dv.Type() {
+		dv.Set(reflect.ValueOf(src))
+		return
+	}
+	if convert_by_registry(dv, src) {
+		return
+	}
+	if scanner, ok := dv.Addr().Interface().(sql.Scanner); ok {
+		if err := scanner.Scan(src); err != nil {
+			panic(dbxErrorNew("convert failed: %T -> %v, error: %v", src, dv.Type(), err.Error()))
+		}
+		return
+	}
+	set_value_to_ifc(dv, src)
+}
+
+func set_null_value(col *Col, dv reflect.Value, nullToZero bool) {
+	switch dv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		dv.Set(reflect.Zero(dv.Type()))
+		return
+	}
+	if scanner, ok := dv.Addr().Interface().(sql.Scanner); ok {
+		if err := scanner.Scan(nil); err != nil {
+			panic(dbxErrorNew("convert failed: NULL -> %v, error: %v", dv.Type(), err.Error()))
+		}
+		return
+	}
+	if nullToZero || col.JSON {
+		dv.Set(reflect.Zero(dv.Type()))
+		return
+	}
+	panic(dbxErrorNew("convert failed: NULL -> %v (column %v), use a pointer or sql.Null* field, or set DB.NullToZero", dv.Type(), col.ColName))
+}
+
+func json_marshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(dbxErrorNew("json marshal failed: %v", err.Error()))
+	}
+	return string(b)
+}
+
+func json_unmarshal(dv reflect.Value, src interface{}) {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		return
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		panic(dbxErrorNew("json unmarshal failed: unsupported type %T", src))
+	}
+	if len(b) == 0 {
+		return
+	}
+	if dv.Kind() == reflect.Ptr && !dv.IsNil() {
+		dv = dv.Elem()
+	}
+	p := reflect.New(dv.Type())
+	if err := json.Unmarshal(b, p.Interface()); err != nil {
+		panic(dbxErrorNew("json unmarshal failed: %v", err.Error()))
+	}
+	dv.Set(p.Elem())
+}
+
+// 将字符串（tag 中的默认值）转换为 t 类型的值
+func str_to_value(s string, t reflect.Type, isJSON bool) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if isJSON {
+		err := json.Unmarshal([]byte(s), v.Addr().Interface())
+		return v, err
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		tm, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+		v.Set(reflect.ValueOf(tm))
+		return v, err
+	}
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return v, fmt.Errorf("unsupported type: %v", t)
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return v, fmt.Errorf("unsupported type: %v", t)
+	}
+	return v, nil
+}
+
 func uint8_to_string(bytes []uint8) string {
 	p := unsafe.Pointer(&bytes)
 	str := *(*string)(p) //cast it to a string pointer and assign the value of this pointer
@@ -654,6 +1055,16 @@ func in_array(v string, arr []string) bool {
 	return false
 }
 
+func value_to_str(src interface{}) (string, bool) {
+	switch v := src.(type) {
+	case []byte:
+		return uint8_to_string(v), true
+	case string:
+		return v, true
+	}
+	return "", false
+}
+
 func set_value_to_ifc(dv reflect.Value, src interface{}) {
 	dk := dv.Kind()
 	if dv.Kind() == reflect.Ptr {
@@ -661,6 +1072,10 @@ func set_value_to_ifc(dv reflect.Value, src interface{}) {
 		dk = dv.Kind()
 	}
 
+	if convert_by_registry(dv, src) {
+		return
+	}
+
 	sv := reflect.ValueOf(src)
 	st := sv.Type()
 	//sk := sv.Kind()
@@ -670,28 +1085,40 @@ func set_value_to_ifc(dv reflect.Value, src interface{}) {
 	//
 	//}
 
-	switch src.(type) {
-	case []byte:
-		if dk == reflect.String {
-			dv.Set(reflect.ValueOf(uint8_to_string(src.([]uint8))))
+	// 数据库返回的文本：MySQL 的 DECIMAL、文本协议的整数等
+	if str, ok := value_to_str(src); ok {
+		var err error
+		switch dk {
+		case reflect.String:
+			dv.SetString(str)
 			return
-		} else if dk == reflect.Float64 || dk == reflect.Float32 {
-			str := uint8_to_string(src.([]uint8))
-			f, err := strconv.ParseFloat(str, 64)
-			if err != nil {
-				panic(dbxErrorNew("convert failed: %v -> %v, error: %v", st, dt, err.Error()))
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			if f, err = strconv.ParseFloat(str, 64); err == nil {
+				dv.SetFloat(f)
+				return
 			}
-			if dk == reflect.Float64 {
-				dv.Set(reflect.ValueOf(f))
-			} else {
-				// convert float64 -> float32
-				dv.Set(sv.Convert(reflect.TypeOf(dt)))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var n int64
+			if n, err = strconv.ParseInt(str, 10, 64); err == nil && !dv.OverflowInt(n) {
+				dv.SetInt(n)
+				return
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var n uint64
+			if n, err = strconv.ParseUint(str, 10, 64); err == nil && !dv.OverflowUint(n) {
+				dv.SetUint(n)
+				return
+			}
+		case reflect.Bool:
+			var b bool
+			if b, err = strconv.ParseBool(str); err == nil {
+				dv.SetBool(b)
+				return
 			}
-			return
 		}
-	case string:
-		if dk != reflect.String {
-			dv.Set(reflect.ValueOf(fmt.Sprintf("%v", src)))
+		if err != nil {
+			panic(dbxErrorNew("convert failed: %v -> %v, error: %v", st, dt, err.Error()))
 		}
 	}
 
@@ -712,36 +1139,159 @@ func set_value_to_ifc(dv reflect.Value, src interface{}) {
 
 }
 
+// v1 = v1 opcode i2，i2 先转换为 v1 的类型，int / uint / float 按照各自的类型计算，
+// 与 SQL 的结果一致：整数的 / 为整除，< 为 LEAST()，> 为 GREATEST()，超出字段类型的范围时报错。
+// 其他类型（如 decimal.Decimal）需要有 Add / Sub / Mul / Div / Mod / Cmp 方法。
 func set_value_to_ifc_int(v1 reflect.Value, opcode string, i2 interface{}) {
-	t1 := v1.Type()
 	if v1.Kind() == reflect.Ptr {
+		if v1.IsNil() {
+			v1.Set(reflect.New(v1.Type().Elem()))
+		}
 		v1 = v1.Elem()
-		t1 = v1.Type()
-	}
-
-	//t2 := reflect.TypeOf(i2)
-	v2 := reflect.ValueOf(i2)
-	if v2.Kind() == reflect.Ptr {
-		v2 = v2.Elem()
-		//t2 = v2.Type()
-	}
-	v2v1 := v2.Convert(t1)
-	if opcode == "+" {
-		v3 := v1.Int() + v2v1.Int()
-		v1.Set(reflect.ValueOf(v3))
-	} else if opcode == "-" {
-		v3 := v1.Int() - v2v1.Int()
-		v1.Set(reflect.ValueOf(v3))
-	} else if opcode == "*" {
-		v3 := v1.Int() * v2v1.Int()
-		v1.Set(reflect.ValueOf(v3))
-	} else if opcode == "%" {
-		v3 := v1.Int() % v2v1.Int()
-		v1.Set(reflect.ValueOf(v3))
-	} else {
-		panic(dbxErrorNew("not support opcde: %v", opcode))
 	}
+	v2 := arg_to_type(i2, v1.Type())
+	if (opcode == "/" || opcode == "%") && v2.IsZero() {
+		panic(dbxErrorNew("division by zero: %v %v", opcode, i2))
+	}
+
+	switch v1.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a, b := v1.Int(), v2.Int()
+		var c int64
+		overflow := false
+		switch opcode {
+		case "+":
+			c = a + b
+			overflow = (c > a) != (b > 0)
+		case "-":
+			c = a - b
+			overflow = (c < a) != (b > 0)
+		case "*":
+			c = a * b
+			overflow = a != 0 && (c/a != b || a == -1 && b == math.MinInt64)
+		case "/":
+			c = a / b
+			overflow = a == math.MinInt64 && b == -1
+		case "%":
+			c = a % b
+		case "<":
+			c = a
+			if b < a {
+				c = b
+			}
+		case ">":
+			c = a
+			if b > a {
+				c = b
+			}
+		default:
+			panic(dbxErrorNew("not support opcde: %v", opcode))
+		}
+		if overflow || v1.OverflowInt(c) {
+			panic(dbxErrorNew("value out of range: %v %v %v (%v)", a, opcode, b, v1.Type()))
+		}
+		v1.SetInt(c)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		a, b := v1.Uint(), v2.Uint()
+		var c uint64
+		overflow := false
+		switch opcode {
+		case "+":
+			c = a + b
+			overflow = c < a
+		case "-":
+			c = a - b
+			overflow = b > a
+		case "*":
+			c = a * b
+			overflow = a != 0 && c/a != b
+		case "/":
+			c = a / b
+		case "%":
+			c = a % b
+		case "<":
+			c = a
+			if b < a {
+				c = b
+			}
+		case ">":
+			c = a
+			if b > a {
+				c = b
+			}
+		default:
+			panic(dbxErrorNew("not support opcde: %v", opcode))
+		}
+		if overflow || v1.OverflowUint(c) {
+			panic(dbxErrorNew("value out of range: %v %v %v (%v)", a, opcode, b, v1.Type()))
+		}
+		v1.SetUint(c)
+	case reflect.Float32, reflect.Float64:
+		a, b := v1.Float(), v2.Float()
+		var c float64
+		switch opcode {
+		case "+":
+			c = a + b
+		case "-":
+			c = a - b
+		case "*":
+			c = a * b
+		case "/":
+			c = a / b
+		case "%":
+			c = math.Mod(a, b)
+		case "<":
+			c = math.Min(a, b)
+		case ">":
+			c = math.Max(a, b)
+		default:
+			panic(dbxErrorNew("not support opcde: %v", opcode))
+		}
+		if !math.IsInf(a, 0) && math.IsInf(c, 0) || v1.OverflowFloat(c) {
+			panic(dbxErrorNew("value out of range: %v %v %v (%v)", a, opcode, b, v1.Type()))
+		}
+		v1.SetFloat(c)
+	default:
+		// decimal
+		names := map[string]string{"+": "Add", "-": "Sub", "*": "Mul", "/": "Div", "%": "Mod", "<": "Cmp", ">": "Cmp"}
+		name, ok := names[opcode]
+		if !ok {
+			panic(dbxErrorNew("not support opcde: %v", opcode))
+		}
+		method := v1.MethodByName(name)
+		if !method.IsValid() {
+			panic(dbxErrorNew("not support opcde: %v on %v, method %v not found", opcode, v1.Type(), name))
+		}
+		out := method.Call([]reflect.Value{v2})
+		if name != "Cmp" {
+			v1.Set(out[0])
+		} else if n := out[0].Int(); opcode == "<" && n > 0 || opcode == ">" && n < 0 {
+			v1.Set(v2)
+		}
+	}
+}
 
+// 将参数转换为 t 类型，UpdateM() 的运算在缓存和 SQL 中使用相同的值
+func arg_to_type(i interface{}, t reflect.Type) reflect.Value {
+	v := reflect.ValueOf(i)
+	for v.Kind() == reflect.Ptr && v.Type() != t {
+		v = v.Elem()
+	}
+	if v.IsValid() && v.Type() == t {
+		return v
+	}
+	ret := reflect.New(t).Elem()
+	if !v.IsValid() {
+		return ret
+	}
+	if scanner, ok := ret.Addr().Interface().(sql.Scanner); ok && !v.Type().ConvertibleTo(t) {
+		if err := scanner.Scan(value_to_arg(v.Interface(), false)); err != nil {
+			panic(dbxErrorNew("convert failed: %v -> %v, error: %v", v.Type(), t, err.Error()))
+		}
+		return ret
+	}
+	set_value_to_ifc(ret, v.Interface())
+	return ret
 }
 
 func get_key_str_by_args(args ...interface{}) string {
@@ -0,0 +1,146 @@
+package dbx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 表名、列名、别名在拼接到 SQL 之前校验并加引号：必须为已知的表结构中的名字，或者为标识符 [A-Za-z_][A-Za-z0-9_]*，
+// 可以带一级前缀（库名.表名、别名.列名），其他的返回 *IdentifierError。需要拼接 SQL 时使用 dbx.Raw() / dbx.Expr()。
+//
+//	if errors.Is(err, dbx.ErrInvalidIdentifier) { ... }
+var ErrInvalidIdentifier = errors.New("dbx: invalid identifier")
+
+type IdentifierError struct {
+	Kind  string // table / column / alias
+	Name  string
+	Table string // 列不存在时的表名
+}
+
+func (e *IdentifierError) Error() string {
+	if e.Table != "" {
+		return fmt.Sprintf("dbx: %v %q does not exists in table %v", e.Kind, e.Name, e.Table)
+	}
+	return fmt.Sprintf("dbx: invalid %v name: %q", e.Kind, e.Name)
+}
+
+func (e *IdentifierError) Unwrap() error {
+	return ErrInvalidIdentifier
+}
+
+var identRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// name 或者 prefix.name
+func valid_ident(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, p := range parts {
+		if !identRegexp.MatchString(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func check_ident(kind string, name string) {
+	if !valid_ident(name) {
+		panic(dbxErrorWrap(&IdentifierError{Kind: kind, Name: name}))
+	}
+}
+
+// MySQL / SQLite 为 `a`.`b`，Cassandra 不加引号（加双引号以后区分大小写）
+func quote_ident(name string, isCQL bool) string {
+	if isCQL {
+		return name
+	}
+	return "`" + strings.Replace(strings.Replace(name, "`", "``", -1), ".", "`.`", -1) + "`"
+}
+
+// FROM / UPDATE / INSERT 中的表名
+func (q *Query) tableSQL() string {
+	if _, ok := q.tableStruct[q.table]; !ok {
+		check_ident("table", q.table)
+	}
+	return quote_ident(q.table, q.isCQL)
+}
+
+// 列名；单表查询并且表结构已知时，必须为表中的列或者 Fields() 中的别名，JOIN、GROUP BY 时由数据库校验
+func (q *Query) colSQL(tableStruct *TableStruct, colName string) string {
+	if tableStruct != nil && tableStruct.ColFieldMap.GetByColName(colName) != nil {
+		return quote_ident(colName, q.isCQL)
+	}
+	check_ident("column", colName)
+	if tableStruct == nil || q.is_projection() {
+		return quote_ident(colName, q.isCQL)
+	}
+	name := colName
+	if i := strings.Index(name, "."); i != -1 && (name[:i] == q.table || name[:i] == q.alias) {
+		name = name[i+1:]
+	}
+	if tableStruct.ColFieldMap.GetByColName(name) != nil {
+		return quote_ident(colName, q.isCQL)
+	}
+	for _, f := range q.fields {
+		if s, ok := f.(string); ok {
+			if m := fieldAliasRegexp.FindStringSubmatch(s); m != nil && m[2] == colName {
+				return quote_ident(colName, q.isCQL)
+			}
+		}
+	}
+	panic(dbxErrorWrap(&IdentifierError{Kind: "column", Name: colName, Table: q.table}))
+}
+
+// col AS alias
+var fieldAliasRegexp = regexp.MustCompile(`^\s*(\S+)\s+(?i:AS)\s+(\S+)\s*$`)
+
+// SELECT 的列：*、别名.*、列名、列名 AS 别名，Expression 原样输出
+func (q *Query) fieldsToSQL(tableStruct *TableStruct) (string, []interface{}) {
+	arr := make([]string, 0, len(q.fields))
+	var args []interface{}
+	for _, f := range q.fields {
+		switch v := f.(type) {
+		case Expression:
+			arr = append(arr, v.SQL)
+			args = append(args, v.Args...)
+		case string:
+			s := strings.TrimSpace(v)
+			switch {
+			case s == "*":
+				arr = append(arr, s)
+			case strings.HasSuffix(s, ".*"):
+				check_ident("table", s[:len(s)-2])
+				arr = append(arr, quote_ident(s[:len(s)-2], q.isCQL)+".*")
+			default:
+				if m := fieldAliasRegexp.FindStringSubmatch(s); m != nil {
+					check_ident("alias", m[2])
+					arr = append(arr, q.colSQL(tableStruct, m[1])+" AS "+quote_ident(m[2], q.isCQL))
+				} else {
+					arr = append(arr, q.colSQL(tableStruct, s))
+				}
+			}
+		}
+	}
+	return strings.Join(arr, ","), args
+}
+
+// JOIN 的表："group"、"group g"、"group AS g"
+func (q *Query) joinTableSQL(table string) string {
+	arr := strings.Fields(table)
+	if len(arr) == 3 && strings.EqualFold(arr[1], "AS") {
+		arr = []string{arr[0], arr[2]}
+	}
+	if len(arr) == 0 || len(arr) > 2 {
+		panic(dbxErrorWrap(&IdentifierError{Kind: "table", Name: table}))
+	}
+	check_ident("table", arr[0])
+	sql1 := quote_ident(arr[0], q.isCQL)
+	if len(arr) == 2 {
+		check_ident("alias", arr[1])
+		sql1 += " " + quote_ident(arr[1], q.isCQL)
+	}
+	return sql1
+}
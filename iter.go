@@ -0,0 +1,361 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// 逐行读取，不会像 All() 一样把结果全部放到内存中：
+//
+//	it, err := db.Table("user").Where("gid=?", 1).Iter(ctx)
+//	defer it.Close()
+//	for it.Next() {
+//		u := &User{}
+//		if err = it.Scan(u); err != nil {
+//			break
+//		}
+//	}
+//	err = it.Err()
+type Iter struct {
+	q   *Query
+	ctx context.Context
+
+	rows    *sql.Rows
+	cqlIter *gocql.Iter
+	scanner gocql.Scanner
+
+	// 第一次 Scan() 时按照 dest 的类型初始化
+	tableStruct *TableStruct
+	isMapper    bool
+	columns     []string
+	posMap      map[int]*Col
+	values      []interface{}
+	holders     []interface{}
+
+	err    error
+	closed bool
+}
+
+// Iterate() / Chunk() 的回调返回 ErrStop 时提前结束，不返回错误
+var ErrStop = errors.New("dbx: stop iteration")
+
+func (q *Query) Iter(ctx context.Context) (it *Iter, err error) {
+	defer dbxErrorDefer(&err, q)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sql1, args := q.toSQL(q.getTableStruct(), ACTION_SELECT_ALL)
+	it = &Iter{q: q, ctx: ctx}
+	if q.isCQL {
+		it.cqlIter = q.CQLSession.Query(sql1, args...).WithContext(ctx).Iter()
+		it.scanner = it.cqlIter.Scanner()
+		q.LogSQL(sql1, args...)
+		return
+	}
+	it.rows, err = q.DB.QueryContext(ctx, sql1, args...)
+	q.LogSQL(sql1, args...)
+	if err != nil {
+		q.ErrorSQL(err.Error(), sql1, args...)
+		return nil, err
+	}
+	return
+}
+
+// 下一行，出错、context 被取消或者没有数据时返回 false，并且自动 Close()
+func (it *Iter) Next() bool {
+	if it.closed {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		it.Close()
+		return false
+	}
+	var ok bool
+	if it.scanner != nil {
+		ok = it.scanner.Next()
+	} else {
+		ok = it.rows.Next()
+	}
+	if !ok {
+		it.Close()
+	}
+	return ok
+}
+
+// dest 为 &struct，每次读取前清空
+func (it *Iter) Scan(dest interface{}) (err error) {
+	defer dbxErrorDefer(&err, it.q)
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Struct {
+		it.q.Panic("Iter.Scan(): must pass a struct pointer: %T", dest)
+	}
+	if it.tableStruct == nil {
+		it.init(destValue.Type())
+	}
+	destValue.Elem().Set(reflect.Zero(destValue.Elem().Type()))
+
+	if it.isMapper {
+		// 生成的代码直接给出字段的指针，跳过反射
+		mapper_scan_dest(dest.(Mapper), it.columns, it.values, it.holders)
+	}
+	if it.scanner != nil {
+		err = it.scanner.Scan(it.values...)
+	} else {
+		err = it.rows.Scan(it.values...)
+	}
+	if err != nil || it.isMapper {
+		return
+	}
+	for k, col := range it.posMap {
+		ifc_pos_to_value(it.tableStruct, it.values[k], col, destValue)
+	}
+	return
+}
+
+// 数据库返回的列，需要和表结构进行对应，与 rows_to_arr_list() / cql_rows_to_arr_list() 相同
+func (it *Iter) init(arrType reflect.Type) {
+	it.tableStruct = it.q.getTableStruct(arrType)
+	it.posMap = map[int]*Col{}
+	var infos []gocql.ColumnInfo
+	if it.cqlIter != nil {
+		infos = it.cqlIter.Columns()
+		it.columns = cql_columns(infos)
+	} else {
+		var err error
+		if it.columns, err = it.rows.Columns(); err != nil {
+			panic(dbxErrorWrap(err))
+		}
+	}
+	it.values = make([]interface{}, len(it.columns))
+	for k, colName := range it.columns {
+		col := it.tableStruct.ColFieldMap.GetByColName(colName)
+		if col != nil {
+			it.posMap[k] = col
+		}
+		if it.cqlIter != nil {
+			if col != nil {
+				it.values[k] = cql_scan_dest(col, infos[k])
+			}
+		} else {
+			it.values[k] = new(interface{})
+		}
+	}
+	it.holders = it.values
+	if it.isMapper = it.tableStruct.is_mapper(); it.isMapper {
+		it.values = make([]interface{}, len(it.columns))
+	}
+}
+
+func (it *Iter) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.scanner != nil {
+		return it.scanner.Err()
+	}
+	if it.rows != nil {
+		return it.rows.Err()
+	}
+	return nil
+}
+
+func (it *Iter) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	var err error
+	if it.cqlIter != nil {
+		err = it.cqlIter.Close()
+	} else if it.rows != nil {
+		err = it.rows.Close()
+	}
+	if it.err == nil {
+		it.err = err
+	}
+	return err
+}
+
+// 逐行回调 fn，fn 为 func(row *T) error，每行为新的 *T，可以保存
+func (q *Query) Iterate(ctx context.Context, fn interface{}) (err error) {
+	defer dbxErrorDefer(&err, q)
+	fnValue := reflect.ValueOf(fn)
+	rowType := check_callback(q, fn, "Iterate", reflect.Ptr)
+	var it *Iter
+	it, err = q.Iter(ctx)
+	if err != nil {
+		return
+	}
+	defer it.Close()
+	for it.Next() {
+		row := reflect.New(rowType.Elem())
+		if err = it.Scan(row.Interface()); err != nil {
+			return
+		}
+		if err = call_callback(fnValue, row); err != nil {
+			if err == ErrStop {
+				err = nil
+			}
+			return
+		}
+	}
+	return it.Err()
+}
+
+// 按照主键分批读取（keyset，不使用 OFFSET），fn 为 func(list []*T) error 或者 func(list []T) error
+func (q *Query) Chunk(ctx context.Context, size int, fn interface{}) (err error) {
+	defer dbxErrorDefer(&err, q)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if size <= 0 {
+		q.Panic("Chunk(): size must be greater than 0: %v", size)
+	}
+	fnValue := reflect.ValueOf(fn)
+	listType := check_callback(q, fn, "Chunk", reflect.Slice)
+	elemType := listType.Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	tableStruct := q.getTableStruct(reflect.New(structType).Type())
+	if len(tableStruct.PrimaryKey) == 0 {
+		q.Panic("Chunk(): table %v has no primary key", q.table)
+	}
+
+	var last []interface{}
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		q2 := q.keyset_query(tableStruct, nil, last)
+		q2.limitStart, q2.limitEnd = int64(size), 0
+		list := reflect.New(listType)
+		err = q2.All(list.Interface())
+		if err != nil && err != ErrNoRows {
+			return
+		}
+		err = nil
+		n := list.Elem().Len()
+		if n == 0 {
+			return
+		}
+		if err = call_callback(fnValue, list.Elem()); err != nil {
+			if err == ErrStop {
+				err = nil
+			}
+			return
+		}
+		if n < size {
+			return
+		}
+		last = get_pk_values_by_pos(tableStruct, list.Elem().Index(n-1))
+	}
+}
+
+// 在 q 的条件上增加 "排序列在 last 之后" 的条件，orderBy 为空时按照主键升序；
+// Cassandra 只能按照 token(分区键) 翻页，主键必须就是分区键。
+func (q *Query) keyset_query(tableStruct *TableStruct, orderBy M, last []interface{}) *Query {
+	if q.isCQL {
+		q.cql_check_keyset(tableStruct)
+	}
+	q2 := *q
+	q2.orderBy = M{}
+	if len(orderBy) == 0 {
+		for _, colName := range tableStruct.PrimaryKey {
+			orderBy = append(orderBy, Map{colName, 1})
+		}
+	}
+	if !q.isCQL {
+		q2.orderBy = orderBy
+	}
+	if last == nil {
+		return &q2
+	}
+	var where string
+	if q.isCQL {
+		pk := strings.Join(tableStruct.partitionKey, ",")
+		where = fmt.Sprintf("token(%v) > token(%v)", pk, strings.TrimRight(strings.Repeat("?,", len(last)), ","))
+	} else {
+		where = keyset_where(orderBy)
+		last = keyset_args(last)
+	}
+	if q.where != "" {
+		where = "(" + q.where + ") AND " + where
+	}
+	q2.where = where
+	q2.whereArgs = append(append([]interface{}{}, q.whereArgs...), last...)
+	return &q2
+}
+
+// token() 只接受分区键；有聚簇列时一个分区有多行，LIMIT 在分区中间截断后，token(分区键) > token(?) 会漏掉该分区剩下的行
+func (q *Query) cql_check_keyset(tableStruct *TableStruct) {
+	if len(tableStruct.clusteringKey) > 0 {
+		q.Panic("table %v has clustering columns %v, Cassandra can only page by the partition key", q.table, tableStruct.clusteringKey)
+	}
+	if strings.Join(tableStruct.PrimaryKey, ",") != strings.Join(tableStruct.partitionKey, ",") {
+		q.Panic("primary key %v of table %v is not the partition key %v, Cassandra can only page by the partition key", tableStruct.PrimaryKey, q.table, tableStruct.partitionKey)
+	}
+}
+
+// (a, b) > (?, ?) 展开为 a > ? OR (a = ? AND b > ?)，兼容 MySQL / SQLite，支持每列不同的排序方向
+func keyset_where(orderBy M) string {
+	arr := make([]string, 0, len(orderBy))
+	for i := range orderBy {
+		conds := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, arr_to_sql_add([]string{orderBy[j].Key}, "=?", "", false))
+		}
+		op := ">?"
+		if order, _ := sort_order(orderBy[i].Value); order == SORT_DESC {
+			op = "<?"
+		}
+		conds = append(conds, arr_to_sql_add([]string{orderBy[i].Key}, op, "", false))
+		arr = append(arr, "("+strings.Join(conds, " AND ")+")")
+	}
+	return "(" + strings.Join(arr, " OR ") + ")"
+}
+
+// 与 keyset_where() 的占位符对应：a, a, b, a, b, c ...
+func keyset_args(last []interface{}) []interface{} {
+	args := make([]interface{}, 0, len(last)*(len(last)+1)/2)
+	for i := range last {
+		args = append(args, last[:i+1]...)
+	}
+	return args
+}
+
+// 按照 PrimaryKey 的顺序返回主键的值
+func get_pk_values_by_pos(tableStruct *TableStruct, row reflect.Value) []interface{} {
+	values := make([]interface{}, len(tableStruct.PrimaryKeyPos))
+	for i, pos := range tableStruct.PrimaryKeyPos {
+		values[i] = value_to_arg(pk_key_value(tableStruct, i, get_value_from_pos(row, pos)), tableStruct.isCQL())
+	}
+	return values
+}
+
+// fn 必须为 func(x) error，返回 x 的类型
+func check_callback(q *Query, fn interface{}, name string, kind reflect.Kind) reflect.Type {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 1 || t.Out(0) != errorType || t.In(0).Kind() != kind {
+		q.Panic("%v(): unexpected callback type: %T", name, fn)
+	}
+	return t.In(0)
+}
+
+func call_callback(fnValue reflect.Value, arg reflect.Value) error {
+	ret := fnValue.Call([]reflect.Value{arg})[0]
+	if ret.IsNil() {
+		return nil
+	}
+	return ret.Interface().(error)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -0,0 +1,107 @@
+package dbx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JOIN 查询，结果读入嵌套的 struct，匿名字段的 db tag 为表的别名，其中的列按照 "别名.列名" 对应：
+//
+//	type UserGroup struct {
+//		User  `db:"u"`
+//		Group `db:"g"`
+//	}
+//	db.Table("user").As("u").LeftJoin("group g", "g.gid=u.gid").Where("u.uid>?", 1).All(&list)
+//
+// 没有指定 Fields() 时自动生成 SELECT `u`.`uid` AS `u.uid`, ...
+type joinClause struct {
+	kind  string // JOIN / LEFT JOIN / INNER JOIN
+	table string // 可以带别名："group g"
+	on    string
+	args  []interface{}
+}
+
+// 主表的别名
+func (q *Query) As(alias string) *Query {
+	q = q.Clone()
+	q.alias = alias
+	return q
+}
+
+func (q *Query) Join(table string, on string, args ...interface{}) *Query {
+	q = q.Clone()
+	q.joins = append(q.joins, joinClause{"JOIN", table, on, args})
+	return q
+}
+
+func (q *Query) LeftJoin(table string, on string, args ...interface{}) *Query {
+	q = q.Clone()
+	q.joins = append(q.joins, joinClause{"LEFT JOIN", table, on, args})
+	return q
+}
+
+func (q *Query) InnerJoin(table string, on string, args ...interface{}) *Query {
+	q = q.Clone()
+	q.joins = append(q.joins, joinClause{"INNER JOIN", table, on, args})
+	return q
+}
+
+// FROM 之后的部分：表名、别名以及 JOIN，args 为 ON 中的参数
+func (q *Query) fromToSQL() (from string, args []interface{}) {
+	from = q.tableSQL()
+	if q.alias != "" {
+		check_ident("alias", q.alias)
+		from += " " + quote_ident(q.alias, q.isCQL)
+	}
+	if len(q.joins) == 0 {
+		return
+	}
+	if q.isCQL {
+		panic(dbxErrorNew("JOIN is not supported by Cassandra"))
+	}
+	for _, j := range q.joins {
+		from += fmt.Sprintf(" %v %v ON %v", j.kind, q.joinTableSQL(j.table), j.on)
+		args = append(args, j.args...)
+	}
+	return
+}
+
+// JOIN 时的 SELECT 列表，有 "别名.列名" 的列时生成 AS，否则为 *
+func join_fields_sql(tableStruct *TableStruct) string {
+	if tableStruct == nil {
+		return "*"
+	}
+	prefixed := false
+	for _, colName := range tableStruct.ColFieldMap.colArr {
+		if strings.Contains(colName, ".") {
+			prefixed = true
+			break
+		}
+	}
+	if !prefixed {
+		return "*"
+	}
+	fields := make([]string, 0, len(tableStruct.ColFieldMap.colArr))
+	for _, colName := range tableStruct.ColFieldMap.colArr {
+		if strings.Contains(colName, ".") {
+			fields = append(fields, fmt.Sprintf("%v AS `%v`", arr_to_sql_add([]string{colName}, "", "", false), colName))
+		} else {
+			fields = append(fields, arr_to_sql_add([]string{colName}, "", "", false))
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// JOIN、GROUP BY 的结果与表的结构不同，按照类型缓存，不注册到 db.tableStruct，也不使用数据缓存
+func (q *Query) getResultTableStruct(arrType reflect.Type) *TableStruct {
+	if arrType.Kind() != reflect.Ptr {
+		arrType = reflect.New(arrType).Type()
+	}
+	if v, ok := q.resultStruct.Load(arrType); ok {
+		return v.(*TableStruct)
+	}
+	tableStruct := NewTableStruct(q.DB, q.table, arrType)
+	q.resultStruct.Store(arrType, tableStruct)
+	return tableStruct
+}
@@ -0,0 +1,132 @@
+package dbx
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type jsonCond struct {
+	col   string
+	path  string
+	op    string
+	value interface{}
+}
+
+// JSON 列的条件，path 为 MySQL / SQLite 的 JSON path：
+//
+//	db.Table("user").WhereJSON("meta", "$.plan", "=", "pro").All(&list)
+//	db.Table("user").WhereJSON("meta", "$.quota[0]", ">", 10).Count()
+//
+// MySQL 为 JSON_UNQUOTE(JSON_EXTRACT())，SQLite 为 json_extract()；开启缓存的表在内存中执行。
+// Cassandra 只支持开启缓存的表。
+func (q *Query) WhereJSON(colName string, path string, op string, value interface{}) *Query {
+	switch op {
+	case "=", "!=", "<>", ">", ">=", "<", "<=":
+	default:
+		q.Panic("WhereJSON(): not support opcode: %v", op)
+	}
+	if _, err := parse_json_path(path); err != nil {
+		q.Panic("WhereJSON(): %v", err.Error())
+	}
+	q = q.Clone()
+	q.whereJSON = append(q.whereJSON, jsonCond{colName, path, op, value})
+	return q
+}
+
+func (q *Query) whereJSONToSQL() (where string, args []interface{}) {
+	arr := make([]string, 0, len(q.whereJSON))
+	for _, c := range q.whereJSON {
+		col := q.colSQL(nil, c.col)
+		switch q.DriverType {
+		case DRIVER_MYSQL:
+			arr = append(arr, "JSON_UNQUOTE(JSON_EXTRACT("+col+", ?))"+c.op+"?")
+		case DRIVER_SQLITE:
+			arr = append(arr, "json_extract("+col+", ?)"+c.op+"?")
+		default:
+			panic(dbxErrorNew("WhereJSON() is not supported by Cassandra without cache"))
+		}
+		args = append(args, c.path, c.value)
+	}
+	where = strings.Join(arr, " AND ")
+	return
+}
+
+// $.a.b[0]
+func parse_json_path(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, dbxErrorNew("json path must start with $: %v", path)
+	}
+	keys := make([]interface{}, 0)
+	s := path[1:]
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			var key string
+			if strings.HasPrefix(s, `"`) {
+				n := strings.Index(s[1:], `"`)
+				if n == -1 {
+					return nil, dbxErrorNew("invalid json path: %v", path)
+				}
+				key, s = s[1:n+1], s[n+2:]
+			} else {
+				n := strings.IndexAny(s, ".[")
+				if n == -1 {
+					n = len(s)
+				}
+				key, s = s[:n], s[n:]
+			}
+			if key == "" {
+				return nil, dbxErrorNew("invalid json path: %v", path)
+			}
+			keys = append(keys, key)
+		case '[':
+			n := strings.Index(s, "]")
+			if n == -1 {
+				return nil, dbxErrorNew("invalid json path: %v", path)
+			}
+			i, err := strconv.Atoi(s[1:n])
+			if err != nil {
+				return nil, dbxErrorNew("invalid json path: %v", path)
+			}
+			keys = append(keys, i)
+			s = s[n+1:]
+		default:
+			return nil, dbxErrorNew("invalid json path: %v", path)
+		}
+	}
+	return keys, nil
+}
+
+// 在内存中按照 path 取值，不存在时 ok 为 false（与 SQL 的 NULL 一致）
+func json_path_value(v interface{}, path string) (ret interface{}, ok bool) {
+	keys, err := parse_json_path(path)
+	if err != nil || v == nil {
+		return nil, false
+	}
+	// 转为 json.Unmarshal 得到的通用结构，与数据库中的 JSON 一致
+	var doc interface{}
+	if err := json.Unmarshal([]byte(json_marshal(v)), &doc); err != nil {
+		return nil, false
+	}
+	for _, key := range keys {
+		switch k := key.(type) {
+		case string:
+			m, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = m[k]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := doc.([]interface{})
+			if !ok || k < 0 || k >= len(arr) {
+				return nil, false
+			}
+			doc = arr[k]
+		}
+	}
+	return doc, doc != nil
+}
@@ -0,0 +1,110 @@
+package dbx
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// 由 dbx mapper / dbx gen --mapper 生成，Bind() 时检测到 &struct 实现了该接口，
+// 读写时就不再按照 FieldPos 逐列反射。
+type Mapper interface {
+	// 将 columns 中每一列对应的字段指针写入 dest，不认识的列保持 nil
+	ScanRow(columns []string, dest []interface{})
+	// 按照 columns 的顺序返回字段的值，缓存的 key 也由主键的值生成
+	Args(columns []string) []interface{}
+}
+
+var mapperType = reflect.TypeOf((*Mapper)(nil)).Elem()
+
+// Mapper 直接 Scan 到字段、直接返回字段的值，需要 set_col_value() / value_to_arg() 转换的列不能使用：
+// json 列、time.Time（TimePolicy）、注册了转换的类型、DB.NullToZero；
+// sql.Scanner / driver.Valuer 由 database/sql 调用，但是 gocql 不认识
+func mapper_col_ok(db *DB, col *Col) bool {
+	t := col.FieldStruct.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if col.JSON || t == timeType || has_converter(t) || db.NullToZero {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	if db.DriverType == DRIVER_CQL && (pt.Implements(scannerType) || pt.Implements(valuerType)) {
+		return false
+	}
+	return true
+}
+
+// 生成的代码只包含有 db tag 的字段，ScanRow() 不认识的列（例如 DB.NamingStrategy 映射的列）不能使用 Mapper
+func mapper_covers(t *TableStruct) bool {
+	m := reflect.New(t.Type.Elem()).Interface().(Mapper)
+	dest := make([]interface{}, len(t.ColFieldMap.colArr))
+	m.ScanRow(t.ColFieldMap.colArr, dest)
+	for _, v := range dest {
+		if v == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func atomic_converters_n() int32 {
+	return atomic.LoadInt32(&convertersN)
+}
+
+// Bind() 以后注册了转换时重新检查每一列，注册了转换的类型不能使用 Mapper
+func (t *TableStruct) is_mapper() bool {
+	if !t.IsMapper || atomic.LoadInt32(&t.mapperOff) == 1 {
+		return false
+	}
+	n := atomic_converters_n()
+	if atomic.LoadInt32(&t.mapperConverters) == n {
+		return true
+	}
+	for _, col := range t.ColFieldMap.cols {
+		if col.ColName != "" && !mapper_col_ok(t.db, col) {
+			atomic.StoreInt32(&t.mapperOff, 1)
+			return false
+		}
+	}
+	atomic.StoreInt32(&t.mapperConverters, n)
+	return true
+}
+
+// 取出实现了 Mapper 的行，row 为 struct 或者 &struct，不可寻址的 struct 返回 nil
+func row_to_mapper(tableStruct *TableStruct, row reflect.Value) Mapper {
+	if tableStruct == nil || !tableStruct.is_mapper() {
+		return nil
+	}
+	if row.Kind() != reflect.Ptr {
+		if !row.CanAddr() {
+			return nil
+		}
+		row = row.Addr()
+	}
+	if row.Type() != tableStruct.Type {
+		return nil
+	}
+	m, _ := row.Interface().(Mapper)
+	return m
+}
+
+// 填充 Scan() 的参数，不认识的列使用 holders 占位，gocql 可以传 nil 跳过该列
+func mapper_scan_dest(m Mapper, columns []string, dest []interface{}, holders []interface{}) {
+	for i := range dest {
+		dest[i] = nil
+	}
+	m.ScanRow(columns, dest)
+	for i := range dest {
+		if dest[i] == nil {
+			dest[i] = holders[i]
+		}
+	}
+}
+
+func sql_scan_holders(n int) []interface{} {
+	holders := make([]interface{}, n)
+	for i := range holders {
+		holders[i] = new(interface{})
+	}
+	return holders
+}
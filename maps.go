@@ -0,0 +1,290 @@
+package dbx
+
+import (
+	"database/sql"
+	"reflect"
+
+	"github.com/gocql/gocql"
+)
+
+// 不需要 struct 的查询，方便临时的管理工具：
+//
+//	list, err := db.Table("user").Where("gid=?", 1).AllMaps()
+//	names := []string{}
+//	err = db.Table("user").Pluck("name", &names)
+//	users := map[int64]*User{}
+//	err = db.Table("user").AllKeyed(&users)
+//	ok, err := db.Table("user").WhereM(dbx.M{{"name", "jack"}}).Exists()
+//
+// 开启缓存的表在条件允许时（没有 SQL 字符串的条件）从缓存中读取。
+
+// 没有数据时返回空的 slice，不返回 ErrNoRows
+func (q *Query) AllMaps() (list []map[string]interface{}, err error) {
+	defer dbxErrorDefer(&err, q)
+	list, err = q.all_maps()
+	return
+}
+
+// 单列的值读入 &[]T，NULL 为 T 的零值（指针为 nil）
+func (q *Query) Pluck(colName string, dest interface{}) (err error) {
+	defer dbxErrorDefer(&err, q)
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		q.Panic("Pluck(): must pass a slice pointer: %T", dest)
+	}
+	q = q.Fields(colName)
+	var list []map[string]interface{}
+	list, err = q.all_maps()
+	if err != nil {
+		return
+	}
+	sliceType := destValue.Elem().Type()
+	slice := reflect.MakeSlice(sliceType, 0, len(list))
+	for _, m := range list {
+		// 数据库返回的列名可能不带表名
+		v, ok := m[colName]
+		if !ok {
+			for _, v2 := range m {
+				v = v2
+			}
+		}
+		ev := reflect.New(sliceType.Elem()).Elem()
+		assign_value(ev, v)
+		slice = reflect.Append(slice, ev)
+	}
+	destValue.Elem().Set(slice)
+	return
+}
+
+// 按照主键读入 &map[K]*T 或者 &map[K]T，联合主键时 K 为 string，与缓存的 key 相同
+func (q *Query) AllKeyed(dest interface{}) (err error) {
+	defer dbxErrorDefer(&err, q)
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Map {
+		q.Panic("AllKeyed(): must pass a map pointer: %T", dest)
+	}
+	destType := destValue.Elem().Type()
+	elemType := destType.Elem()
+	elemIsPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if elemIsPtr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		q.Panic("AllKeyed(): map value must be a struct or a struct pointer: %v", elemType)
+	}
+	tableStruct := q.getTableStruct(reflect.New(structType).Type())
+	if len(tableStruct.PrimaryKey) == 0 {
+		q.Panic("AllKeyed(): table %v has no primary key", q.table)
+	}
+	if len(tableStruct.PrimaryKey) > 1 && destType.Key().Kind() != reflect.String {
+		q.Panic("AllKeyed(): map key must be string for composite primary key: %v", destType.Key())
+	}
+
+	rows := make([]reflect.Value, 0)
+	if len(q.fields) == 0 && !q.is_projection() && tableStruct.Type.Elem() == structType && q.memory_enabled(tableStruct) {
+		for _, row := range q.memory_rows(tableStruct) {
+			row2 := reflect.New(structType)
+			row2.Elem().Set(row.Elem())
+			rows = append(rows, row2)
+		}
+	} else {
+		list := reflect_make_slice_pointer(reflect.New(structType).Type())
+		err = q.All(list)
+		if err != nil && err != ErrNoRows {
+			return
+		}
+		err = nil
+		listValue := reflect.ValueOf(list).Elem()
+		for i := 0; i < listValue.Len(); i++ {
+			rows = append(rows, listValue.Index(i))
+		}
+	}
+
+	m := reflect.MakeMapWithSize(destType, len(rows))
+	for _, row := range rows {
+		key := reflect.New(destType.Key()).Elem()
+		if len(tableStruct.PrimaryKey) == 1 {
+			assign_value(key, get_value_from_pos(row, tableStruct.PrimaryKeyPos[0]))
+		} else {
+			key.SetString(get_pk_keys(tableStruct, row.Elem()))
+		}
+		if elemIsPtr {
+			m.SetMapIndex(key, row)
+		} else {
+			m.SetMapIndex(key, row.Elem())
+		}
+	}
+	destValue.Elem().Set(m)
+	return
+}
+
+// 是否存在符合条件的行
+func (q *Query) Exists() (ok bool, err error) {
+	defer dbxErrorDefer(&err, q)
+	tableStruct := q.getTableStruct()
+
+	// 主键条件，与 One() 相同，开启缓存时只查缓存
+	if q.tableEnableCache && tableStruct != nil && tableStruct.EnableCache && len(q.primaryArgs) > 0 {
+		if mp, ok2 := q.tableData[q.table]; ok2 {
+			_, ok = mp.Load(get_pk_key_by_args(tableStruct, q.primaryArgs))
+			return
+		}
+	}
+	if !q.is_projection() && q.memory_enabled(tableStruct) {
+		q.tableData[q.table].Range(func(k, v interface{}) bool {
+			ok = q.memory_match(tableStruct, reflect.ValueOf(v).Elem())
+			return !ok
+		})
+		return
+	}
+
+	// Cassandra 不支持 SELECT 1
+	if q.isCQL && tableStruct != nil {
+		q = q.Clone()
+		q.fields = make([]interface{}, len(tableStruct.PrimaryKey))
+		for i, colName := range tableStruct.PrimaryKey {
+			q.fields[i] = colName
+		}
+	} else if !q.isCQL {
+		q = q.Fields(Raw("1"))
+	}
+	q = q.Limit(1)
+	var list []map[string]interface{}
+	list, err = q.all_maps()
+	ok = len(list) > 0
+	return
+}
+
+// 读入 []map[string]interface{}，缓存与数据库的值由 driver_value() 统一类型：NULL 为 nil，整数为 int64，浮点数为 float64，[]byte 为 string
+func (q *Query) all_maps() (list []map[string]interface{}, err error) {
+	list = make([]map[string]interface{}, 0)
+	if columns, rows, ok := q.memory_aggregate(); ok {
+		for _, values := range rows {
+			m := make(map[string]interface{}, len(columns))
+			for i, colName := range columns {
+				m[colName] = values[i]
+			}
+			list = append(list, m)
+		}
+		return
+	}
+	if columns, cols, ok := q.memory_columns(); ok {
+		tableStruct := q.getTableStruct()
+		for _, row := range q.memory_rows(tableStruct) {
+			m := make(map[string]interface{}, len(columns))
+			for i, colName := range columns {
+				m[colName] = col_db_value(cols[i], get_value_from_pos(row, cols[i].FieldPos), q.isCQL)
+			}
+			list = append(list, m)
+		}
+		return
+	}
+	sql1, args := q.toSQL(q.getTableStruct(), ACTION_SELECT_ALL)
+	if q.isCQL {
+		var iter *gocql.Iter
+		iter, err = q.CQLQuery(sql1, args...)
+		if err != nil || iter == nil {
+			return
+		}
+		for {
+			m := map[string]interface{}{}
+			if !iter.MapScan(m) {
+				break
+			}
+			for k, v := range m {
+				m[k] = driver_value(v)
+			}
+			list = append(list, m)
+		}
+		err = iter.Close()
+		return
+	}
+	var rows *sql.Rows
+	rows, err = q.SQLQuery(sql1, args...)
+	if err != nil || rows == nil {
+		return
+	}
+	defer rows.Close()
+	var columns []string
+	columns, err = rows.Columns()
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		if err = rows.Scan(values...); err != nil {
+			return
+		}
+		m := make(map[string]interface{}, len(columns))
+		for i, colName := range columns {
+			m[colName] = driver_value(*(values[i].(*interface{})))
+		}
+		list = append(list, m)
+	}
+	err = rows.Err()
+	return
+}
+
+// 在缓存中读取时的列：Fields() / Select() 中的列名，默认为全部的列；有表达式时 ok 为 false
+func (q *Query) memory_columns() (columns []string, cols []*Col, ok bool) {
+	tableStruct := q.getTableStruct()
+	if q.is_projection() || !q.memory_enabled(tableStruct) {
+		return
+	}
+	for _, f := range q.fields {
+		colName, ok := f.(string)
+		if !ok {
+			return nil, nil, false
+		}
+		columns = append(columns, colName)
+	}
+	if len(columns) == 0 {
+		for _, f := range q.selects {
+			columns = append(columns, f.(string))
+		}
+	}
+	if len(columns) == 0 {
+		columns = tableStruct.ColFieldMap.colArr
+	}
+	cols = make([]*Col, len(columns))
+	for i, colName := range columns {
+		if cols[i] = tableStruct.ColFieldMap.GetByColName(colName); cols[i] == nil {
+			return nil, nil, false
+		}
+	}
+	return columns, cols, true
+}
+
+// 将数据库或者缓存中的值赋给 dv，NULL 为零值
+func assign_value(dv reflect.Value, src interface{}) {
+	if src == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return
+	}
+	if dv.Kind() == reflect.Ptr {
+		dv.Set(reflect.New(dv.Type().Elem()))
+		dv = dv.Elem()
+	}
+	if reflect.TypeOf(src) == dv.Type() {
+		dv.Set(reflect.ValueOf(src))
+		return
+	}
+	if dv.Kind() == reflect.Interface {
+		dv.Set(reflect.ValueOf(src))
+		return
+	}
+	if convert_by_registry(dv, src) {
+		return
+	}
+	if scanner, ok := dv.Addr().Interface().(sql.Scanner); ok {
+		if err := scanner.Scan(src); err != nil {
+			panic(dbxErrorNew("convert failed: %T -> %v, error: %v", src, dv.Type(), err.Error()))
+		}
+		return
+	}
+	set_value_to_ifc(dv, src)
+}
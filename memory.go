@@ -0,0 +1,238 @@
+package dbx
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 开启缓存的表，部分查询直接在内存中执行，不访问数据库
+
+// 比较两个值，NULL 或者类型不能比较时 ok 为 false；数字统一转为 float64，bool 视为 0/1
+func compare_values(a, b interface{}) (n int, ok bool) {
+	a, b = normalize_value(a), normalize_value(b)
+	if a == nil || b == nil {
+		return 0, a == nil && b == nil
+	}
+	switch va := a.(type) {
+	case float64:
+		vb, ok := to_float64(b)
+		if !ok {
+			return 0, false
+		}
+		return compare_float64(va, vb), true
+	case string:
+		if vb, ok := b.(string); ok {
+			return compare_string(va, vb), true
+		}
+		if vb, ok := b.(float64); ok {
+			fa, err := strconv.ParseFloat(va, 64)
+			if err != nil {
+				return 0, false
+			}
+			return compare_float64(fa, vb), true
+		}
+	case time.Time:
+		if vb, ok := b.(time.Time); ok {
+			if va.Before(vb) {
+				return -1, true
+			} else if va.After(vb) {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func normalize_value(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	// sql.NullInt64、注册了转换的类型等按照写入数据库的值比较
+	if rv.Kind() == reflect.Struct {
+		rv = reflect.ValueOf(value_to_arg(rv.Interface(), false))
+		if !rv.IsValid() {
+			return nil
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		if rv.Bool() {
+			return float64(1)
+		}
+		return float64(0)
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if b, ok := rv.Interface().([]byte); ok {
+			return string(b)
+		}
+	}
+	return rv.Interface()
+}
+
+func to_float64(v interface{}) (float64, bool) {
+	switch vv := v.(type) {
+	case float64:
+		return vv, true
+	case string:
+		f, err := strconv.ParseFloat(vv, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func compare_float64(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compare_string(a, b string) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// 按照比较运算符判断
+func compare_op(a interface{}, op string, b interface{}) bool {
+	n, ok := compare_values(a, b)
+	if !ok {
+		if normalize_value(a) == nil || normalize_value(b) == nil {
+			return false // 与 SQL 的 NULL 一致，不匹配
+		}
+		panic(dbxErrorNew("can not compare %T with %T in cache", a, b))
+	}
+	switch op {
+	case "=":
+		return n == 0
+	case "!=", "<>":
+		return n != 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	}
+	panic(dbxErrorNew("not support opcode: %v", op))
+}
+
+// 是否可以在内存中执行：开启了缓存，条件中没有 SQL 字符串、表达式
+func (q *Query) memory_enabled(tableStruct *TableStruct) bool {
+	if !q.tableEnableCache || tableStruct == nil || !tableStruct.EnableCache {
+		return false
+	}
+	if len(q.primaryArgs) > 0 || q.where != "" || len(q.joins) > 0 || q.has_sql_expr() {
+		return false
+	}
+	_, ok := q.tableData[q.table]
+	return ok
+}
+
+// 在缓存中查找符合 WhereM() / WhereJSON() 的行，按照 Sort() 排序（默认按照主键），再执行 Limit()
+func (q *Query) memory_rows(tableStruct *TableStruct) []reflect.Value {
+	mp := q.tableData[q.table]
+	rows := make([]reflect.Value, 0)
+	mp.Range(func(k, v interface{}) bool {
+		row := reflect.ValueOf(v)
+		if q.memory_match(tableStruct, row.Elem()) {
+			rows = append(rows, row)
+		}
+		return true
+	})
+
+	orderBy := q.orderBy
+	if len(orderBy) == 0 {
+		for _, colName := range tableStruct.PrimaryKey {
+			orderBy = append(orderBy, Map{colName, 1})
+		}
+	}
+	cols := make([]*Col, len(orderBy))
+	for i, m := range orderBy {
+		cols[i] = tableStruct.ColFieldMap.GetByColName(m.Key)
+		if cols[i] == nil {
+			panic(dbxErrorNew("sort column does not exists: %v", m.Key))
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, m := range orderBy {
+			a := get_value_from_pos(rows[i].Elem(), cols[k].FieldPos)
+			b := get_value_from_pos(rows[j].Elem(), cols[k].FieldPos)
+			order, nulls := sort_order(m.Value)
+			if n := q.compare_sort(a, b, order, nulls); n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+
+	start, end := q.limit_bounds(len(rows))
+	return rows[start:end]
+}
+
+// LIMIT n / LIMIT start,n 对应的下标
+func (q *Query) limit_bounds(l int) (start int, end int) {
+	if q.limitStart == 0 && q.limitEnd == 0 {
+		return 0, l
+	}
+	start2, n := int64(0), q.limitStart
+	if q.limitEnd != 0 {
+		start2, n = q.limitStart, q.limitEnd
+	}
+	if start2 > int64(l) {
+		start2 = int64(l)
+	}
+	end2 := start2 + n
+	if end2 > int64(l) {
+		end2 = int64(l)
+	}
+	return int(start2), int(end2)
+}
+
+func (q *Query) memory_match(tableStruct *TableStruct, row reflect.Value) bool {
+	for _, m := range q.whereM {
+		colName, op := split_where_key(m.Key)
+		col := tableStruct.ColFieldMap.GetByColName(colName)
+		if col == nil {
+			panic(dbxErrorNew("column does not exists: %v", colName))
+		}
+		if !where_m_match(get_value_from_pos(row, col.FieldPos), op, m.Value) {
+			return false
+		}
+	}
+	for _, c := range q.whereJSON {
+		col := tableStruct.ColFieldMap.GetByColName(c.col)
+		if col == nil {
+			panic(dbxErrorNew("column does not exists: %v", c.col))
+		}
+		v, ok := json_path_value(get_value_from_pos(row, col.FieldPos), c.path)
+		if !ok || !compare_op(v, c.op, c.value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package dbx
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 没有 db tag 的字段按照 DB.NamingStrategy 生成列名，db tag 总是优先：
+//
+//	db.NamingStrategy = dbx.SnakeCase // CreateDate -> create_date
+//	db.NamingStrategy = dbx.CamelCase // CreateDate -> createDate
+//
+// 为 nil 时（默认）没有 db tag 的字段不对应任何列。
+// 需要在 Bind() 或者第一次使用表之前设置。
+type NamingStrategy func(fieldName string) string
+
+var (
+	SnakeCase NamingStrategy = snake_case
+	CamelCase NamingStrategy = camel_case
+	Identity  NamingStrategy = func(fieldName string) string { return fieldName }
+)
+
+// UserID -> user_id, HTTPServer -> http_server
+func snake_case(s string) string {
+	rs := []rune(s)
+	b := strings.Builder{}
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(rs[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// CreateDate -> createDate, UserID -> userID, ID -> id
+func camel_case(s string) string {
+	rs := []rune(s)
+	for i := range rs {
+		// 开头连续的大写字母转为小写，保留最后一个作为下一个单词的开头
+		if !unicode.IsUpper(rs[i]) || (i > 0 && i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+			break
+		}
+		rs[i] = unicode.ToLower(rs[i])
+	}
+	return string(rs)
+}
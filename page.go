@@ -0,0 +1,226 @@
+package dbx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 游标翻页（keyset），不使用 OFFSET，深度翻页不会变慢：
+//
+//	list := []*User{}
+//	next, err := db.Table("user").Sort("gid", -1).Page("", 20, &list)
+//	next, err = db.Table("user").Sort("gid", -1).Page(next, 20, &list) // 下一页，next 为空时没有更多数据
+//
+// 按照 Sort() 排序，最后补充主键保证顺序稳定，排序的列必须为 NOT NULL；Cassandra 按照 token(分区键) 的顺序，忽略 Sort()。
+// 开启缓存的表在内存中排序、翻页。
+type pageCursor struct {
+	Keys   []string      `json:"k"` // 排序的列，翻页时必须与当前的排序一致
+	Values []interface{} `json:"v"`
+}
+
+func (q *Query) Page(cursor string, size int, dest interface{}) (next string, err error) {
+	defer dbxErrorDefer(&err, q)
+	if size <= 0 {
+		q.Panic("Page(): size must be greater than 0: %v", size)
+	}
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		q.Panic("Page(): must pass a slice pointer: %T", dest)
+	}
+	listType := destValue.Elem().Type()
+	elemIsPtr := listType.Elem().Kind() == reflect.Ptr
+	structType := listType.Elem()
+	if elemIsPtr {
+		structType = structType.Elem()
+	}
+	tableStruct := q.getTableStruct(reflect.New(structType).Type())
+	if len(tableStruct.PrimaryKey) == 0 {
+		q.Panic("Page(): table %v has no primary key", q.table)
+	}
+
+	orderBy := q.page_order_by(tableStruct)
+	cols := make([]*Col, len(orderBy))
+	keys := make([]string, len(orderBy))
+	for i, m := range orderBy {
+		keys[i] = m.Key
+		if cols[i] = tableStruct.ColFieldMap.GetByColName(m.Key); cols[i] == nil {
+			q.Panic("Page(): sort column does not exists: %v", m.Key)
+		}
+	}
+	q.page_check_nullable(orderBy)
+	var last []interface{}
+	if cursor != "" {
+		last = decode_cursor(cursor, keys)
+	}
+
+	// 多取一行，判断是否有下一页
+	rows := make([]reflect.Value, 0, size+1)
+	if len(q.fields) == 0 && !q.is_projection() && tableStruct.Type.Elem() == structType && q.memory_enabled(tableStruct) {
+		rows = q.memory_page(tableStruct, orderBy, cols, last, size+1)
+	} else {
+		q2 := q.keyset_query(tableStruct, orderBy, last)
+		q2.limitStart, q2.limitEnd = int64(size+1), 0
+		list := reflect_make_slice_pointer(reflect.New(structType).Type())
+		err = q2.All(list)
+		if err != nil && err != ErrNoRows {
+			return
+		}
+		err = nil
+		listValue := reflect.ValueOf(list).Elem()
+		for i := 0; i < listValue.Len(); i++ {
+			rows = append(rows, listValue.Index(i))
+		}
+	}
+
+	if len(rows) > size {
+		rows = rows[:size]
+		next = encode_cursor(keys, page_cursor_values(tableStruct, cols, rows[size-1]))
+	}
+	slice := reflect.MakeSlice(listType, 0, len(rows))
+	for _, row := range rows {
+		if elemIsPtr {
+			slice = reflect.Append(slice, row)
+		} else {
+			slice = reflect.Append(slice, row.Elem())
+		}
+	}
+	destValue.Elem().Set(slice)
+	return
+}
+
+// Sort() 的列，再补充不在其中的主键；Cassandra 为分区键
+func (q *Query) page_order_by(tableStruct *TableStruct) M {
+	orderBy := M{}
+	if !q.isCQL {
+		for _, m := range q.orderBy {
+			order, _ := sort_order(m.Value)
+			orderBy = append(orderBy, Map{m.Key, order})
+		}
+	}
+	for _, colName := range tableStruct.PrimaryKey {
+		exists := false
+		for _, m := range orderBy {
+			if m.Key == colName {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			orderBy = append(orderBy, Map{colName, 1})
+		}
+	}
+	return orderBy
+}
+
+// 可以为 NULL 的列不能用于排序：NULL 与游标中的值比较不成立，翻页时会漏掉或者重复行
+func (q *Query) page_check_nullable(orderBy M) {
+	if q.isCQL {
+		return
+	}
+	var nullable map[string]bool
+	if v, ok := q.nullableCols.Load(q.table); ok {
+		nullable = v.(map[string]bool)
+	} else {
+		cols, err := q.TableColumns(q.table)
+		if err != nil {
+			panic(dbxErrorWrap(err))
+		}
+		nullable = map[string]bool{}
+		for _, col := range cols {
+			nullable[col.Name] = col.Nullable
+		}
+		q.nullableCols.Store(q.table, nullable)
+	}
+	for _, m := range orderBy {
+		if nullable[m.Key] {
+			q.Panic("Page(): sort column %v can be NULL, use a NOT NULL column", m.Key)
+		}
+	}
+}
+
+// 游标中保存数据库中的值：time.Time 按照 TimePolicy 转换，其他类型同写入时的转换
+func page_cursor_values(tableStruct *TableStruct, cols []*Col, row reflect.Value) []interface{} {
+	values := make([]interface{}, len(cols))
+	for i, col := range cols {
+		v := get_value_from_pos(row, col.FieldPos)
+		if tm, ok := v.(time.Time); ok {
+			v = tableStruct.time_policy(col).to_db(tm, tableStruct.isCQL())
+		} else {
+			v = value_to_arg(v, tableStruct.isCQL())
+		}
+		values[i] = v
+	}
+	return values
+}
+
+func encode_cursor(keys []string, values []interface{}) string {
+	b, err := json.Marshal(pageCursor{keys, values})
+	if err != nil {
+		panic(dbxErrorNew("Page(): encode cursor failed: %v", err.Error()))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// 整数不转换为 float64，避免大的主键丢失精度
+func decode_cursor(cursor string, keys []string) []interface{} {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		panic(dbxErrorNew("Page(): invalid cursor: %v", err.Error()))
+	}
+	var c pageCursor
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&c); err != nil {
+		panic(dbxErrorNew("Page(): invalid cursor: %v", err.Error()))
+	}
+	if strings.Join(c.Keys, ",") != strings.Join(keys, ",") || len(c.Values) != len(keys) {
+		panic(dbxErrorNew("Page(): cursor does not match the sort: %v, %v", c.Keys, keys))
+	}
+	for i, v := range c.Values {
+		if n, ok := v.(json.Number); ok {
+			if i64, err := n.Int64(); err == nil {
+				c.Values[i] = i64
+			} else {
+				c.Values[i], _ = n.Float64()
+			}
+		}
+	}
+	return c.Values
+}
+
+// 在缓存中排序，返回 last 之后的 n 行（缓存的拷贝）
+func (q *Query) memory_page(tableStruct *TableStruct, orderBy M, cols []*Col, last []interface{}, n int) []reflect.Value {
+	q2 := *q
+	q2.orderBy = orderBy
+	q2.limitStart, q2.limitEnd = 0, 0
+	rows := q2.memory_rows(tableStruct)
+
+	start := 0
+	if last != nil {
+		start = sort.Search(len(rows), func(i int) bool {
+			values := page_cursor_values(tableStruct, cols, rows[i])
+			for k, m := range orderBy {
+				if c := q.compare_sort(values[k], last[k], m.Value.(int), NULLS_DEFAULT); c != 0 {
+					return c > 0
+				}
+			}
+			return false
+		})
+	}
+	end := start + n
+	if end > len(rows) {
+		end = len(rows)
+	}
+	ret := make([]reflect.Value, 0, end-start)
+	for _, row := range rows[start:end] {
+		row2 := reflect.New(tableStruct.Type.Elem())
+		row2.Elem().Set(row.Elem())
+		ret = append(ret, row2)
+	}
+	return ret
+}
@@ -0,0 +1,60 @@
+package dbx
+
+import (
+	"reflect"
+)
+
+// 按页码分页，同时返回总行数：
+//
+//	list := []*User{}
+//	p, err := db.Table("user").Where("gid=?", 1).Sort("uid", -1).Paginate(2, 20, &list)
+//	// p.Total, p.TotalPages, p.HasNext, p.HasPrev
+//
+// 总数与当前页使用相同的条件，不修改 q；开启缓存并且没有条件的表直接使用缓存的行数。
+// 深度翻页请使用 Page()，Cassandra 不支持 OFFSET，只能使用 Page()。
+type Pagination struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+	HasNext    bool  `json:"has_next"`
+	HasPrev    bool  `json:"has_prev"`
+}
+
+// page 从 1 开始，小于 1 时为 1；超出最后一页时 dest 为空列表，不返回 ErrNoRows
+func (q *Query) Paginate(page int, perPage int, dest interface{}) (p Pagination, err error) {
+	defer dbxErrorDefer(&err, q)
+	if q.isCQL {
+		q.Panic("Paginate(): OFFSET is not supported by Cassandra, use Page()")
+	}
+	if perPage <= 0 {
+		q.Panic("Paginate(): perPage must be greater than 0: %v", perPage)
+	}
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		q.Panic("Paginate(): must pass a slice pointer: %T", dest)
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	q2 := *q
+	q2.limitStart, q2.limitEnd = 0, 0
+	p = Pagination{Page: page, PerPage: perPage}
+	if p.Total, err = q2.Count(); err != nil {
+		return
+	}
+	p.TotalPages = int((p.Total + int64(perPage) - 1) / int64(perPage))
+	p.HasPrev = page > 1
+	p.HasNext = page < p.TotalPages
+
+	destValue.Elem().Set(reflect.MakeSlice(destValue.Elem().Type(), 0, 0))
+	if int64(page-1)*int64(perPage) >= p.Total {
+		return
+	}
+	q2.limitStart, q2.limitEnd = int64(page-1)*int64(perPage), int64(perPage)
+	if err = q2.All(dest); err == ErrNoRows {
+		err = nil
+	}
+	return
+}
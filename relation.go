@@ -0,0 +1,287 @@
+package dbx
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 关联，用于 Preload() 批量加载，避免 N+1 查询：
+//
+//	type User struct {
+//		Uid   int64   `db:"uid"`
+//		Posts []*Post `dbx:"hasmany:post,fk=uid"`
+//	}
+//	db.Table("user").Preload("Posts").All(&users)
+const (
+	RELATION_HASONE    = "hasone"    // 子表的 fk 指向父表的 ref（默认主键），一条
+	RELATION_HASMANY   = "hasmany"   // 子表的 fk 指向父表的 ref（默认主键），多条
+	RELATION_BELONGSTO = "belongsto" // 父表的 fk 指向子表的 ref（默认主键）
+)
+
+// 每次 IN (...) 的参数个数
+const RELATION_BATCH_SIZE = 500
+
+type Relation struct {
+	Kind  string
+	Table string // 关联的表名
+	FK    string // 外键列名
+	Ref   string // 被引用的列名，为空时使用主键
+	Field *Col   // 保存关联数据的字段
+}
+
+/*
+dbx:"hasmany:post,fk=uid"
+dbx:"hasone:profile,fk=uid,ref=uid"
+dbx:"belongsto:user,fk=uid"
+*/
+func parse_relation_tag(tag string) *Relation {
+	arr := strings.Split(tag, ",")
+	kindTable := strings.SplitN(strings.TrimSpace(arr[0]), ":", 2)
+	if len(kindTable) != 2 || kindTable[1] == "" {
+		panic(dbxErrorNew("invalid dbx tag: %v, expect kind:table", tag))
+	}
+	r := &Relation{Kind: kindTable[0], Table: kindTable[1]}
+	switch r.Kind {
+	case RELATION_HASONE, RELATION_HASMANY, RELATION_BELONGSTO:
+	default:
+		panic(dbxErrorNew("unknown relation kind: %v, tag: %v", r.Kind, tag))
+	}
+	for i := 1; i < len(arr); i++ {
+		opt := strings.TrimSpace(arr[i])
+		switch {
+		case strings.HasPrefix(opt, "fk="):
+			r.FK = opt[len("fk="):]
+		case strings.HasPrefix(opt, "ref="):
+			r.Ref = opt[len("ref="):]
+		case opt == "":
+		default:
+			panic(dbxErrorNew("unknown dbx tag option: %v, tag: %v", opt, tag))
+		}
+	}
+	if r.FK == "" {
+		panic(dbxErrorNew("relation requires fk=: %v", tag))
+	}
+	return r
+}
+
+// 不方便修改 struct tag 时，在 Bind() 之后声明关联：
+//
+//	db.BindRelation("user", "Posts", "hasmany:post,fk=uid")
+func (db *DB) BindRelation(tableName string, fieldName string, tag string) {
+	tableStruct, ok := db.tableStruct[tableName]
+	if !ok {
+		panic(dbxErrorNew("BindRelation(): table %v is not bound", tableName))
+	}
+	col := tableStruct.ColFieldMap.GetByFieldName(fieldName)
+	if col == nil {
+		panic(dbxErrorNew("BindRelation(): field %v does not exists in %v", fieldName, tableStruct.Type))
+	}
+	if col.ColName != "" {
+		panic(dbxErrorNew("BindRelation(): field %v is mapped to column %v", fieldName, col.ColName))
+	}
+	r := parse_relation_tag(tag)
+	r.Field = col
+	col.Relation = r
+	tableStruct.Relations[fieldName] = r
+}
+
+// 查询结果中需要加载的关联字段
+func (q *Query) Preload(fields ...string) *Query {
+	q = q.Clone()
+	q.preload = append(q.preload, fields...)
+	return q
+}
+
+// rows 为 &struct
+func (q *Query) preload_rows(tableStruct *TableStruct, rows []reflect.Value) {
+	if len(rows) == 0 {
+		return
+	}
+	for _, name := range q.preload {
+		r, ok := tableStruct.Relations[name]
+		if !ok {
+			panic(dbxErrorNew("Preload(): relation %v does not exists in %v", name, tableStruct.Type))
+		}
+		q.preload_relation(tableStruct, r, rows)
+	}
+}
+
+// 字段的类型：hasmany 为 []struct / []*struct，其他为 struct / *struct
+func relation_struct_type(r *Relation) reflect.Type {
+	t := r.Field.FieldStruct.Type
+	if r.Kind == RELATION_HASMANY {
+		if t.Kind() != reflect.Slice {
+			panic(dbxErrorNew("hasmany field %v must be a slice", r.Field.FieldName))
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(dbxErrorNew("relation field %v must be a struct or a slice of struct", r.Field.FieldName))
+	}
+	return t
+}
+
+// 单列主键的列名
+func single_pk(tableStruct *TableStruct, tableName string) string {
+	if len(tableStruct.PrimaryKey) != 1 {
+		panic(dbxErrorNew("relation requires ref= on table %v, primary key: %v", tableName, tableStruct.PrimaryKey))
+	}
+	return tableStruct.PrimaryKey[0]
+}
+
+func relation_col(tableStruct *TableStruct, colName string) *Col {
+	col := tableStruct.ColFieldMap.GetByColName(colName)
+	if col == nil {
+		panic(dbxErrorNew("relation column does not exists: %v in %v", colName, tableStruct.Type))
+	}
+	return col
+}
+
+func (q *Query) preload_relation(tableStruct *TableStruct, r *Relation, rows []reflect.Value) {
+	childType := relation_struct_type(r)
+	childStruct := q.DB.Table(r.Table).getTableStruct(reflect.New(childType).Type())
+	if childStruct.Type.Elem() != childType {
+		panic(dbxErrorNew("relation %v: table %v is bound to %v, not %v", r.Field.FieldName, r.Table, childStruct.Type.Elem(), childType))
+	}
+
+	// 父表用于匹配的列，子表用于匹配的列
+	var parentCol, childCol *Col
+	if r.Kind == RELATION_BELONGSTO {
+		parentCol = relation_col(tableStruct, r.FK)
+		ref := r.Ref
+		if ref == "" {
+			ref = single_pk(childStruct, r.Table)
+		}
+		childCol = relation_col(childStruct, ref)
+	} else {
+		ref := r.Ref
+		if ref == "" {
+			ref = single_pk(tableStruct, q.table)
+		}
+		parentCol = relation_col(tableStruct, ref)
+		childCol = relation_col(childStruct, r.FK)
+	}
+
+	// 去重，NULL 不参与匹配
+	keys := make([]interface{}, 0, len(rows))
+	keyMap := map[string]bool{}
+	for _, row := range rows {
+		v := get_value_from_pos(row, parentCol.FieldPos)
+		if v == nil {
+			continue
+		}
+		k := fmt.Sprint(v)
+		if !keyMap[k] {
+			keyMap[k] = true
+			keys = append(keys, v)
+		}
+	}
+
+	children := q.relation_children(r, childStruct, childCol, keys, keyMap)
+	group := map[string][]reflect.Value{}
+	for _, child := range children {
+		k := fmt.Sprint(get_value_from_pos(child, childCol.FieldPos))
+		group[k] = append(group[k], child)
+	}
+
+	for _, row := range rows {
+		field := get_reflect_field_from_pos(row, r.Field.FieldPos)
+		var list []reflect.Value
+		if v := get_value_from_pos(row, parentCol.FieldPos); v != nil {
+			list = group[fmt.Sprint(v)]
+		}
+		isPtr := field.Kind() == reflect.Ptr
+		if r.Kind == RELATION_HASMANY {
+			isPtr = field.Type().Elem().Kind() == reflect.Ptr
+			slice := reflect.MakeSlice(field.Type(), 0, len(list))
+			for _, child := range list {
+				if isPtr {
+					slice = reflect.Append(slice, child)
+				} else {
+					slice = reflect.Append(slice, child.Elem())
+				}
+			}
+			field.Set(slice)
+		} else if len(list) == 0 {
+			field.Set(reflect.Zero(field.Type()))
+		} else if isPtr {
+			field.Set(list[0])
+		} else {
+			field.Set(list[0].Elem())
+		}
+	}
+}
+
+// 查询子表中匹配 keys 的行，按照主键排序；开启缓存时从缓存中复制，不访问数据库
+func (q *Query) relation_children(r *Relation, childStruct *TableStruct, childCol *Col, keys []interface{}, keyMap map[string]bool) []reflect.Value {
+	children := make([]reflect.Value, 0)
+	if len(keys) == 0 {
+		return children
+	}
+	childQ := q.DB.Table(r.Table)
+	if childQ.memory_enabled(childStruct) {
+		mp := q.tableData[r.Table]
+		copy_row := func(v interface{}) {
+			row := reflect.New(childStruct.Type.Elem())
+			row.Elem().Set(reflect.ValueOf(v).Elem())
+			children = append(children, row)
+		}
+		if len(childStruct.PrimaryKey) == 1 && childStruct.PrimaryKey[0] == childCol.ColName {
+			// 按主键直接查找
+			for _, k := range keys {
+				if v, ok := mp.Load(get_pk_key_by_args(childStruct, []interface{}{k})); ok {
+					copy_row(v)
+				}
+			}
+		} else {
+			mp.Range(func(k, v interface{}) bool {
+				if keyMap[fmt.Sprint(get_value_from_pos(reflect.ValueOf(v), childCol.FieldPos))] {
+					copy_row(v)
+				}
+				return true
+			})
+		}
+		sort_rows_by_pk(childStruct, children)
+		return children
+	}
+
+	for start := 0; start < len(keys); start += RELATION_BATCH_SIZE {
+		end := start + RELATION_BATCH_SIZE
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+		where := fmt.Sprintf("%v IN (%v)", arr_to_sql_add([]string{childCol.ColName}, "", "", q.isCQL), strings.TrimRight(strings.Repeat("?,", len(chunk)), ","))
+		childQ = q.DB.Table(r.Table).Where(where, chunk...)
+		for _, colName := range childStruct.PrimaryKey {
+			childQ = childQ.Sort(colName, 1)
+		}
+		list := reflect_make_slice_pointer(childStruct.Type)
+		err := childQ.All(list)
+		if err != nil && err != ErrNoRows {
+			panic(dbxErrorNew("Preload(%v): %v", r.Field.FieldName, err))
+		}
+		listValue := reflect.ValueOf(list).Elem()
+		for i := 0; i < listValue.Len(); i++ {
+			children = append(children, listValue.Index(i))
+		}
+	}
+	return children
+}
+
+func sort_rows_by_pk(tableStruct *TableStruct, rows []reflect.Value) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, pos := range tableStruct.PrimaryKeyPos {
+			n, _ := compare_values(get_value_from_pos(rows[i], pos), get_value_from_pos(rows[j], pos))
+			if n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+}
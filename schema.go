@@ -0,0 +1,185 @@
+package dbx
+
+import (
+	"sort"
+	"strings"
+)
+
+// 表中的一列，来自数据库的元数据，供代码生成等工具使用
+type TableColumn struct {
+	Name          string // 列名: uid
+	Type          string // 数据库中的类型，小写: int / varchar / datetime / text
+	Unsigned      bool   // MySQL unsigned
+	Nullable      bool   // 是否允许 NULL
+	PrimaryKey    bool   // 是否为主键的一部分
+	AutoIncrement bool   // 是否为自增列
+}
+
+// 当前库中所有的表名，按照字母排序
+func (db *DB) TableNames() (names []string, err error) {
+	names = make([]string, 0)
+	if db.DriverType == DRIVER_CQL {
+		if db.CQLMeta == nil {
+			return names, dbxErrorNew("keyspace metadata does not exists: %v", db.DbName)
+		}
+		for name := range db.CQLMeta.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return
+	}
+
+	var sql1 string
+	var args []interface{}
+	if db.DriverType == DRIVER_MYSQL {
+		sql1 = "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA=? AND TABLE_TYPE='BASE TABLE'"
+		args = []interface{}{db.DbName}
+	} else {
+		sql1 = "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'"
+	}
+	rows, err := db.Query(sql1, args...)
+	db.LogSQL(sql1, args...)
+	if err != nil {
+		db.ErrorSQL(err.Error(), sql1, args...)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+		names = append(names, name)
+	}
+	err = rows.Err()
+	sort.Strings(names)
+	return
+}
+
+// 表的所有列，按照建表的顺序；主键和自增列与 get_table_info() 的结果保持一致
+func (db *DB) TableColumns(tableName string) (cols []*TableColumn, err error) {
+	defer func() {
+		if err1 := recover(); err1 != nil {
+			if !dbxErrorType(err1) {
+				panic(err1)
+			}
+			err = err1.(*dbxError)
+		}
+	}()
+
+	switch db.DriverType {
+	case DRIVER_CQL:
+		cols, err = cql_get_columns(db, tableName)
+	case DRIVER_SQLITE:
+		cols, err = sqlite_get_columns(db, tableName)
+	default:
+		cols, err = mysql_get_columns(db, tableName)
+	}
+	if err != nil {
+		return
+	}
+
+	pk, autoIncrement := get_table_info(db, tableName)
+	for _, col := range cols {
+		col.PrimaryKey = in_array(col.Name, pk)
+		col.AutoIncrement = (col.Name == autoIncrement)
+		if col.PrimaryKey {
+			col.Nullable = false
+		}
+	}
+	return
+}
+
+func mysql_get_columns(db *DB, tableName string) (cols []*TableColumn, err error) {
+	sql1 := "SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION"
+	rows, err := db.Query(sql1, db.DbName, tableName)
+	db.LogSQL(sql1, db.DbName, tableName)
+	if err != nil {
+		db.ErrorSQL(err.Error(), sql1, db.DbName, tableName)
+		return
+	}
+	defer rows.Close()
+
+	cols = make([]*TableColumn, 0)
+	for rows.Next() {
+		var name, dataType, columnType, nullable string
+		if err = rows.Scan(&name, &dataType, &columnType, &nullable); err != nil {
+			return
+		}
+		cols = append(cols, &TableColumn{
+			Name:     name,
+			Type:     strings.ToLower(dataType),
+			Unsigned: strings.Contains(strings.ToLower(columnType), "unsigned"),
+			Nullable: nullable == "YES",
+		})
+	}
+	err = rows.Err()
+	if err == nil && len(cols) == 0 {
+		err = dbxErrorNew("table does not exists: %v", tableName)
+	}
+	return
+}
+
+func sqlite_get_columns(db *DB, tableName string) (cols []*TableColumn, err error) {
+	sql1 := "SELECT cid, name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?)"
+	rows, err := db.Query(sql1, tableName)
+	db.LogSQL(sql1, tableName)
+	if err != nil {
+		db.ErrorSQL(err.Error(), sql1, tableName)
+		return
+	}
+	defer rows.Close()
+
+	cols = make([]*TableColumn, 0)
+	for rows.Next() {
+		var cid, notNull, pk int64
+		var name, dataType string
+		var defaultValue interface{}
+		if err = rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
+			return
+		}
+		// INT(11) -> int
+		dataType = strings.ToLower(strings.TrimSpace(dataType))
+		if i := strings.Index(dataType, "("); i != -1 {
+			dataType = strings.TrimSpace(dataType[0:i])
+		}
+		cols = append(cols, &TableColumn{
+			Name:     name,
+			Type:     dataType,
+			Unsigned: strings.Contains(dataType, "unsigned"),
+			Nullable: notNull == 0,
+		})
+	}
+	err = rows.Err()
+	if err == nil && len(cols) == 0 {
+		err = dbxErrorNew("table does not exists: %v", tableName)
+	}
+	return
+}
+
+func cql_get_columns(db *DB, tableName string) (cols []*TableColumn, err error) {
+	if db.CQLMeta == nil {
+		return nil, dbxErrorNew("keyspace metadata does not exists: %v", db.DbName)
+	}
+	table, ok := db.CQLMeta.Tables[tableName]
+	if !ok {
+		return nil, dbxErrorNew("table does not exists: %v", tableName)
+	}
+	cols = make([]*TableColumn, 0)
+	for _, name := range table.OrderedColumns {
+		col, ok := table.Columns[name]
+		if !ok {
+			continue
+		}
+		dataType := ""
+		if col.Type != nil {
+			dataType = strings.ToLower(col.Type.Type().String())
+		}
+		cols = append(cols, &TableColumn{
+			Name:     name,
+			Type:     dataType,
+			Nullable: false,
+		})
+	}
+	return
+}
@@ -0,0 +1,49 @@
+package dbx
+
+// 链式调用时每一步返回新的 *Query，不修改调用者，基础查询可以复用、跨 goroutine 共享：
+//
+//	base := db.Table("user").Where("gid=?", 1)
+//	n, err := base.Count()
+//	err = base.Sort("uid", -1).Limit(10).All(&list) // base 不变
+func (q *Query) Clone() *Query {
+	q2 := *q
+	q2.joins = append([]joinClause(nil), q.joins...)
+	q2.fields = append([]interface{}(nil), q.fields...)
+	q2.selects = append([]interface{}(nil), q.selects...)
+	q2.groupBy = append([]string(nil), q.groupBy...)
+	q2.havingArgs = append([]interface{}(nil), q.havingArgs...)
+	q2.primaryArgs = append([]interface{}{}, q.primaryArgs...)
+	q2.whereArgs = append([]interface{}{}, q.whereArgs...)
+	q2.whereM = append(M(nil), q.whereM...)
+	q2.whereJSON = append([]jsonCond(nil), q.whereJSON...)
+	q2.preload = append([]string(nil), q.preload...)
+	q2.orderBy = append(M{}, q.orderBy...)
+	q2.updateFields = append([]string(nil), q.updateFields...)
+	q2.updateOps = append([]string(nil), q.updateOps...)
+	q2.updateArgs = append([]interface{}{}, q.updateArgs...)
+	return &q2
+}
+
+// 命名的查询条件，注册一次，多处使用：
+//
+//	db.Scope("active", func(q *dbx.Query) *dbx.Query { return q.Where("status=?", 1) })
+//	db.Table("user").Scopes("active").All(&list)
+func (db *DB) Scope(name string, fn func(q *Query) *Query) {
+	if fn == nil {
+		panic(dbxErrorNew("Scope(): fn is nil: %v", name))
+	}
+	db.scopes.Store(name, fn)
+}
+
+// 按照顺序应用 Scope() 注册的条件，不存在时 panic
+func (q *Query) Scopes(names ...string) *Query {
+	q = q.Clone()
+	for _, name := range names {
+		v, ok := q.scopes.Load(name)
+		if !ok {
+			q.Panic("Scopes(): scope does not exists: %v", name)
+		}
+		q = v.(func(q *Query) *Query)(q)
+	}
+	return q
+}
@@ -0,0 +1,161 @@
+package dbx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 排序方向
+const (
+	SORT_ASC  = 1
+	SORT_DESC = -1
+)
+
+// NULL 的位置，默认与 MySQL / SQLite 一致：升序时在前，降序时在后
+const (
+	NULLS_DEFAULT = 0
+	NULLS_FIRST   = 1
+	NULLS_LAST    = 2
+)
+
+// 带表达式或者 NULLS 的排序，否则 Map.Value 为 int
+type sortOpt struct {
+	expr  *Expression
+	order int
+	nulls int
+}
+
+// colName 为列名或者 Expression，列名在执行时校验，必须为表中的列：
+//
+//	Sort("gid", dbx.SORT_DESC)
+//	Sort("score", dbx.SORT_DESC, dbx.NULLS_LAST)
+//	Sort(dbx.Expr("FIELD(gid,?,?)", 3, 1), dbx.SORT_ASC)
+func (q *Query) Sort(colName interface{}, order int, nulls ...int) *Query {
+	q = q.Clone()
+	opt := sortOpt{order: order}
+	if len(nulls) > 0 {
+		opt.nulls = nulls[0]
+	}
+	switch v := colName.(type) {
+	case string:
+		if opt.nulls == NULLS_DEFAULT {
+			q.orderBy = append(q.orderBy, Map{v, order})
+		} else {
+			q.orderBy = append(q.orderBy, Map{v, opt})
+		}
+	case Expression:
+		opt.expr = &v
+		q.orderBy = append(q.orderBy, Map{v.SQL, opt})
+	default:
+		q.Panic("Sort(): expect column name or dbx.Expression, got %T", colName)
+	}
+	return q
+}
+
+func (q *Query) SortM(m M) *Query {
+	q = q.Clone()
+	for _, v := range m {
+		q.orderBy = append(q.orderBy, Map{v.Key, v.Value})
+	}
+	return q
+}
+
+// 用户输入的排序，例如 URL 中的 sort=-created,name：逗号分隔，- 为降序；
+// 只接受 allowed 中的列，其他的忽略，用户输入不会拼接到 SQL 中
+func (q *Query) SortBy(spec string, allowed ...string) *Query {
+	q = q.Clone()
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		order := SORT_ASC
+		if strings.HasPrefix(key, "-") {
+			order, key = SORT_DESC, key[1:]
+		} else {
+			key = strings.TrimPrefix(key, "+")
+		}
+		if key == "" || index_of(allowed, key) == -1 {
+			continue
+		}
+		q.orderBy = append(q.orderBy, Map{key, order})
+	}
+	return q
+}
+
+// 排序方向和 NULL 的位置，不是 -1 的均为升序
+func sort_order(v interface{}) (order int, nulls int) {
+	switch o := v.(type) {
+	case int:
+		order = o
+	case sortOpt:
+		order, nulls = o.order, o.nulls
+	}
+	if order != SORT_DESC {
+		order = SORT_ASC
+	}
+	return
+}
+
+// 多列排序以逗号分隔：ORDER BY `gid` DESC,`id` ASC
+func (q *Query) orderByToSQL(tableStruct *TableStruct) (string, []interface{}) {
+	arr := make([]string, 0, len(q.orderBy))
+	var args []interface{}
+	for _, m := range q.orderBy {
+		order, nulls := sort_order(m.Value)
+		var col string
+		var colArgs []interface{}
+		if opt, ok := m.Value.(sortOpt); ok && opt.expr != nil {
+			col, colArgs = opt.expr.SQL, opt.expr.Args
+		} else {
+			col = q.colSQL(tableStruct, m.Key)
+		}
+		dir := "ASC"
+		if order == SORT_DESC {
+			dir = "DESC"
+		}
+		switch {
+		case nulls == NULLS_DEFAULT:
+			arr = append(arr, col+" "+dir)
+		case q.DriverType == DRIVER_SQLITE:
+			arr = append(arr, fmt.Sprintf("%v %v NULLS %v", col, dir, map[int]string{NULLS_FIRST: "FIRST", NULLS_LAST: "LAST"}[nulls]))
+		case q.DriverType == DRIVER_MYSQL:
+			// MySQL 不支持 NULLS FIRST / LAST，先按照 IS NULL 排序
+			isNull := map[int]string{NULLS_FIRST: "DESC", NULLS_LAST: "ASC"}[nulls]
+			arr = append(arr, fmt.Sprintf("%v IS NULL %v,%v %v", col, isNull, col, dir))
+			args = append(args, colArgs...)
+		default:
+			panic(dbxErrorNew("NULLS FIRST / LAST is not supported by Cassandra"))
+		}
+		args = append(args, colArgs...)
+	}
+	return strings.Join(arr, ","), args
+}
+
+// 缓存中排序时的比较，与数据库一致：NULL 最小，可以通过 NULLS_FIRST / NULLS_LAST 指定；
+// MySQL 默认的排序规则不区分大小写。返回值小于 0 时 a 排在前面。
+func (q *Query) compare_sort(a, b interface{}, order int, nulls int) int {
+	a, b = normalize_value(a), normalize_value(b)
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0
+		}
+		nullFirst := nulls == NULLS_FIRST || (nulls == NULLS_DEFAULT && order != SORT_DESC)
+		if (a == nil) == nullFirst {
+			return -1
+		}
+		return 1
+	}
+	if q.DriverType == DRIVER_MYSQL {
+		sa, ok1 := a.(string)
+		sb, ok2 := b.(string)
+		if ok1 && ok2 {
+			a, b = strings.ToLower(sa), strings.ToLower(sb)
+		}
+	}
+	n, ok := compare_values(a, b)
+	if !ok {
+		panic(dbxErrorNew("can not compare %T with %T in cache", a, b))
+	}
+	if order == SORT_DESC {
+		n = -n
+	}
+	return n
+}
@@ -1,6 +1,8 @@
 package cql
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/xiuno/dbx"
 	"gotest.tools/assert"
@@ -239,3 +241,52 @@ func TestCqlMulti(t *testing.T) {
 
 
 }
+
+type Event struct {
+	Uid  int64  `db:"uid"`
+	Seq  int64  `db:"seq"`
+	Name string `db:"name"`
+}
+
+func TestCqlChunk(t *testing.T) {
+
+	initCql()
+
+	// 按照 token(分区键) 翻页
+	for i := int64(1); i <= 3; i++ {
+		_, err = db.Table("user").Insert(&User{Uid: i, Gid: 1, Name: "chunk", CreateDate: time.Now()})
+		assert.Equal(t, err, nil)
+	}
+	db.Bind("user", &User{}, false)
+	out := &bytes.Buffer{}
+	db.Stdout = out
+	n := 0
+	err = db.Table("user").Chunk(context.Background(), 2, func(list []*User) error {
+		n += len(list)
+		return nil
+	})
+	db.Stdout = os.Stdout
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 3)
+	assert.Assert(t, bytes.Contains(out.Bytes(), []byte("WHERE token(uid) > token(")), out.String())
+
+	// 有聚簇列的表不能按照 token() 翻页，会漏掉同一个分区中的行
+	_, err = db.Exec(`DROP TABLE IF EXISTS event;`)
+	_, err = db.Exec(`CREATE TABLE event
+		(
+		  uid  int,
+		  seq  int,
+		  name TEXT,
+		  PRIMARY KEY (uid, seq)
+		);
+	`)
+	assert.Equal(t, err, nil)
+	// 表结构在 Open() 时读取
+	db, err = dbx.Open("cql", "root@tcp(192.168.0.129:9042)/test")
+	assert.Equal(t, err, nil)
+	db.Bind("event", &Event{}, false)
+	err = db.Table("event").Chunk(context.Background(), 2, func(list []*Event) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "clustering columns")
+}
@@ -58,6 +58,10 @@ func TestOne(t *testing.T) {
 
 	var n int64
 
+	// 表不存在时返回错误，而不是空的列
+	_, err = db.TableColumns("user_not_exists")
+	assert.ErrorContains(t, err, "table does not exists")
+
 	// 时间这里有坑，格式化以后的时间可能丢掉微秒
 	now := time.Now()
 
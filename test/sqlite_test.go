@@ -1,10 +1,19 @@
 package test
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/xiuno/dbx"
+	"github.com/xiuno/dbx/gen"
 	"gotest.tools/assert"
+	"net"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -53,6 +62,19 @@ func initSqlite() {
 
 }
 
+// 同一组检查分别走 SQL、走缓存：每次执行 ddl 重新建表，按照 tables 绑定（表名 -> &struct）并加载缓存
+func sqliteEachCache(t *testing.T, ddl string, tables dbx.M, check func()) {
+	for _, enableCache := range []bool{false, true} {
+		_, err = db.Exec(ddl)
+		assert.Equal(t, err, nil)
+		for _, m := range tables {
+			db.Bind(m.Key, m.Value, enableCache)
+			db.Table(m.Key).LoadCache()
+		}
+		check()
+	}
+}
+
 func TestSqliteOne(t *testing.T) {
 
 	initSqlite()
@@ -236,3 +258,2189 @@ func TestSqliteMulti(t *testing.T) {
 
 
 }
+
+func TestSqliteGen(t *testing.T) {
+
+	initSqlite()
+
+	cols, err := db.TableColumns("user")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(cols), 4)
+	assert.Equal(t, cols[0].Name, "uid")
+	assert.Equal(t, cols[0].PrimaryKey, true)
+	assert.Equal(t, cols[0].AutoIncrement, true)
+	assert.Equal(t, cols[2].Nullable, true)
+	_, err = db.TableColumns("user_not_exists")
+	assert.ErrorContains(t, err, "table does not exists")
+
+	tables, err := gen.Load(db, []string{"user"})
+	assert.Equal(t, err, nil)
+	src, err := gen.Render("models", tables[0])
+	assert.Equal(t, err, nil)
+	assert.Assert(t, strings.Contains(string(src), "Uid        int64          `db:\"uid,pk,autoincr\"`"))
+	assert.Assert(t, strings.Contains(string(src), "Name       sql.NullString `db:\"name\"`"))
+	assert.Assert(t, strings.Contains(string(src), "func (r *UserRepo) ByPK(uid int64) (*User, error)"))
+
+	// 按照整个单词匹配类型
+	typ, _ := gen.GoType(dbx.DRIVER_MYSQL, &dbx.TableColumn{Type: "point"})
+	assert.Equal(t, typ, "[]byte")
+	typ, _ = gen.GoType(dbx.DRIVER_MYSQL, &dbx.TableColumn{Type: "bigint", Unsigned: true, Nullable: true})
+	assert.Equal(t, typ, "*uint64")
+	typ, _ = gen.GoType(dbx.DRIVER_SQLITE, &dbx.TableColumn{Type: "unsigned big int"})
+	assert.Equal(t, typ, "int64")
+
+	// 文件名不能被 go build 当作约束
+	assert.Equal(t, gen.FileName("user"), "user")
+	assert.Equal(t, gen.FileName("foo_test"), "foo_test_table")
+	assert.Equal(t, gen.FileName("x_linux"), "x_linux_table")
+	assert.Equal(t, gen.FileName("bind"), "bind_table")
+	assert.Equal(t, gen.FileName("_tmp.log"), "tmp_log")
+	assert.Equal(t, gen.StructName("bind"), "BindModel")
+
+	// 生成的名字冲突
+	_, err = db.Exec("DROP TABLE IF EXISTS user_repo; CREATE TABLE user_repo(id INTEGER PRIMARY KEY, user_id INTEGER, userId INTEGER)")
+	assert.Equal(t, err, nil)
+	_, err = gen.Load(db, []string{"user_repo"})
+	assert.ErrorContains(t, err, "both mapped to field UserId")
+	_, err = db.Exec("DROP TABLE user_repo; CREATE TABLE user_repo(id INTEGER PRIMARY KEY)")
+	assert.Equal(t, err, nil)
+	_, err = gen.Load(db, []string{"user", "user_repo"})
+	assert.ErrorContains(t, err, "conflicts with table user")
+
+	// 嵌套的结构体的 db tag 为列名前缀，与 Bind() 相同
+	file := t.TempDir() + "/model.go"
+	err = os.WriteFile(file, []byte("package m\n\ntype Human struct {\n\tUid int64 `db:\"uid\"`\n}\n\ntype Member struct {\n\tHuman `db:\"h\"`\n\tName string `db:\"name\"`\n}\n"), 0644)
+	assert.Equal(t, err, nil)
+	_, tables, err = gen.ParseFile(file, []string{"Member"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tables[0].Fields[0].Column, "h.uid")
+	assert.Equal(t, tables[0].Fields[0].Name, "Human.Uid")
+}
+
+// 手写的 dbx.Mapper，与 dbx mapper 生成的代码相同
+type UserMapper struct {
+	Uid  int64  `db:"uid"`
+	Gid  int64  `db:"gid"`
+	Name string `db:"name"`
+}
+
+func (r *UserMapper) ScanRow(columns []string, dest []interface{}) {
+	for i, col := range columns {
+		switch col {
+		case "uid":
+			dest[i] = &r.Uid
+		case "gid":
+			dest[i] = &r.Gid
+		case "name":
+			dest[i] = &r.Name
+		}
+	}
+}
+
+func (r *UserMapper) Args(columns []string) []interface{} {
+	args := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "uid":
+			args[i] = r.Uid
+		case "gid":
+			args[i] = r.Gid
+		case "name":
+			args[i] = r.Name
+		}
+	}
+	return args
+}
+
+func TestSqliteMapper(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS user_mapper;
+		CREATE TABLE user_mapper
+		(
+		  uid        INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid        INTEGER NOT NULL DEFAULT '0',
+		  name       TEXT             DEFAULT '',
+		  createDate DATETIME         DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("user_mapper", &UserMapper{}, true)
+	db.LoadCache()
+
+	for i := int64(1); i < 5; i++ {
+		_, err = db.Table("user_mapper").Insert(&UserMapper{Uid: i, Gid: i, Name: fmt.Sprintf("name-%v", i)})
+		assert.Equal(t, err, nil)
+	}
+
+	// 走缓存
+	u := &UserMapper{}
+	err = db.Table("user_mapper").WherePK(2).One(u)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, u.Name, "name-2")
+
+	// 走 SQL
+	err = db.Table("user_mapper").Where("gid=?", 3).One(u)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, u.Uid, int64(3))
+
+	list := []UserMapper{}
+	err = db.Table("user_mapper").Where("uid>?", 1).Sort("uid", 1).All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 3)
+	assert.Equal(t, list[2].Name, "name-4")
+
+	// 重新加载缓存
+	db.Table("user_mapper").LoadCache()
+	n, err := db.Table("user_mapper").Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(4))
+
+	// time.Time 列需要按照 TimePolicy 转换，退回到反射；缓存的 key 与 WherePK() 一致
+	_, err = db.Exec(`DROP TABLE IF EXISTS event_mapper;
+		CREATE TABLE event_mapper
+		(
+		  at   TEXT PRIMARY KEY,
+		  name TEXT NOT NULL DEFAULT ''
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("event_mapper", &EventMapper{}, true)
+	db.LoadCache()
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.Local)
+	_, err = db.Table("event_mapper").Insert(&EventMapper{At: at, Name: "a"})
+	assert.Equal(t, err, nil)
+	db.Table("event_mapper").LoadCache()
+	e := &EventMapper{}
+	err = db.Table("event_mapper").WherePK(at).One(e)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, e.Name, "a")
+	assert.Assert(t, e.At.Equal(at))
+
+	// DB.NamingStrategy 映射的列不在生成的代码中，退回到反射
+	db.NamingStrategy = dbx.SnakeCase
+	_, err = db.Exec(`DROP TABLE IF EXISTS note_mapper;
+		CREATE TABLE note_mapper
+		(
+		  id        INTEGER PRIMARY KEY,
+		  note_text TEXT NOT NULL DEFAULT ''
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("note_mapper", &NoteMapper{}, false)
+	_, err = db.Table("note_mapper").Insert(&NoteMapper{Id: 1, NoteText: "x"})
+	assert.Equal(t, err, nil)
+	nm := &NoteMapper{}
+	err = db.Table("note_mapper").WherePK(1).One(nm)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, nm.NoteText, "x")
+
+	// Bind() 以后注册的转换同样生效
+	_, err = db.Exec(`DROP TABLE IF EXISTS level_mapper;
+		CREATE TABLE level_mapper
+		(
+		  id INTEGER PRIMARY KEY,
+		  lv INTEGER NOT NULL DEFAULT 0
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("level_mapper", &LevelMapper{}, false)
+	dbx.RegisterWriteConverter(reflect.TypeOf(Level(0)), reflect.TypeOf(int64(0)), func(v interface{}) (interface{}, error) {
+		return int64(v.(Level)) * 10, nil
+	})
+	dbx.RegisterConverter(reflect.TypeOf(int64(0)), reflect.TypeOf(Level(0)), func(v interface{}) (interface{}, error) {
+		return Level(v.(int64) / 10), nil
+	})
+	_, err = db.Table("level_mapper").Insert(&LevelMapper{Id: 1, Lv: 3})
+	assert.Equal(t, err, nil)
+	var lv int64
+	err = db.QueryRow("SELECT lv FROM level_mapper WHERE id=1").Scan(&lv)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, lv, int64(30))
+	lm := &LevelMapper{}
+	err = db.Table("level_mapper").WherePK(1).One(lm)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, lm.Lv, Level(3))
+}
+
+// 生成的代码只包含有 db tag 的字段
+type NoteMapper struct {
+	Id       int64 `db:"id"`
+	NoteText string
+}
+
+func (r *NoteMapper) ScanRow(columns []string, dest []interface{}) {
+	for i, col := range columns {
+		switch col {
+		case "id":
+			dest[i] = &r.Id
+		}
+	}
+}
+
+func (r *NoteMapper) Args(columns []string) []interface{} {
+	args := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "id":
+			args[i] = r.Id
+		}
+	}
+	return args
+}
+
+type Level int64
+
+type LevelMapper struct {
+	Id int64 `db:"id"`
+	Lv Level `db:"lv"`
+}
+
+func (r *LevelMapper) ScanRow(columns []string, dest []interface{}) {
+	for i, col := range columns {
+		switch col {
+		case "id":
+			dest[i] = &r.Id
+		case "lv":
+			dest[i] = &r.Lv
+		}
+	}
+}
+
+func (r *LevelMapper) Args(columns []string) []interface{} {
+	args := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "id":
+			args[i] = r.Id
+		case "lv":
+			args[i] = r.Lv
+		}
+	}
+	return args
+}
+
+type EventMapper struct {
+	At   time.Time `db:"at,pk,time=ms"`
+	Name string    `db:"name"`
+}
+
+func (r *EventMapper) ScanRow(columns []string, dest []interface{}) {
+	for i, col := range columns {
+		switch col {
+		case "at":
+			dest[i] = &r.At
+		case "name":
+			dest[i] = &r.Name
+		}
+	}
+}
+
+func (r *EventMapper) Args(columns []string) []interface{} {
+	args := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "at":
+			args[i] = r.At
+		case "name":
+			args[i] = r.Name
+		}
+	}
+	return args
+}
+
+func TestSqliteGeneric(t *testing.T) {
+
+	initSqlite()
+
+	now := time.Now()
+	users := dbx.T[User](db, "user")
+	for i := int64(1); i < 5; i++ {
+		_, err = users.Insert(&User{Uid: i, Gid: i % 2, Name: fmt.Sprintf("name-%v", i), CreateDate: now})
+		assert.Equal(t, err, nil)
+	}
+
+	u, err := dbx.T[User](db, "user").ByPK(2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, u.Name, "name-2")
+
+	u, err = dbx.T[User](db, "user").ByPK(100)
+	assert.Equal(t, err, dbx.ErrNoRows)
+	assert.Assert(t, u == nil)
+
+	list, err := dbx.T[User](db, "user").Where("gid=?", 1).Sort("uid", -1).All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].Uid, int64(3))
+
+	list, err = dbx.T[User](db, "user").Where("gid=?", 100).All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 0)
+
+	u, err = dbx.T[User](db, "user").Where("gid=?", 0).First()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, u.Uid, int64(2))
+
+	u.Name = "jet"
+	_, err = dbx.T[User](db, "user").Update(u)
+	assert.Equal(t, err, nil)
+	u, err = dbx.T[User](db, "user").ByPK(2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, u.Name, "jet")
+
+	n, err := dbx.T[User](db, "user").WherePK(2).Delete()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(1))
+}
+
+type Article struct {
+	Aid    int64    `db:"aid,pk"`
+	Title  string   `db:"title,default=untitled, draft"`
+	Tags   []string `db:"tags,json"`
+	Status int64    `db:"status,omitempty"`
+	Views  int64    `db:"views,readonly"`
+	Memo   string   `db:"-"`
+}
+
+func TestSqliteTagOptions(t *testing.T) {
+
+	initSqlite()
+
+	// 表中没有声明主键，由 db tag 指定
+	_, err = db.Exec(`DROP TABLE IF EXISTS article;
+		CREATE TABLE article
+		(
+		  aid    INTEGER NOT NULL,
+		  title  TEXT    NOT NULL DEFAULT '',
+		  tags   TEXT    NOT NULL DEFAULT '[]',
+		  status INTEGER NOT NULL DEFAULT '1',
+		  views  INTEGER NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("article", &Article{}, true)
+	db.LoadCache()
+
+	a := &Article{Aid: 1, Tags: []string{"a", "b"}, Views: 100, Memo: "memo"}
+	_, err = db.Table("article").Insert(a)
+	assert.Equal(t, err, nil)
+	// default 写回结构体，omitempty / readonly 的列从数据库重新读取
+	assert.Equal(t, a.Title, "untitled, draft")
+	assert.Equal(t, a.Status, int64(1))
+	assert.Equal(t, a.Views, int64(0))
+
+	// 走缓存
+	a2 := &Article{}
+	err = db.Table("article").WherePK(1).One(a2)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, a2.Tags, []string{"a", "b"})
+	assert.Equal(t, a2.Status, int64(1))
+
+	// 只读列不会被更新
+	a2.Title = "hello"
+	a2.Views = 5
+	_, err = db.Table("article").Update(a2)
+	assert.Equal(t, err, nil)
+	_, err = db.Table("article").WherePK(1).UpdateM(dbx.M{{"tags", []string{"c"}}, {"views", 9}})
+	assert.Equal(t, err, nil)
+
+	// 走 SQL
+	a3 := &Article{}
+	err = db.Table("article").Where("aid=?", 1).One(a3)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, a3.Title, "hello")
+	assert.DeepEqual(t, a3.Tags, []string{"c"})
+	assert.Equal(t, a3.Views, int64(0))
+	assert.Equal(t, a3.Memo, "")
+
+	err = db.Table("article").WherePK(1).One(a2)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, a2.Tags, []string{"c"})
+	assert.Equal(t, a2.Views, int64(0))
+}
+
+type Member struct {
+	MemberID   int64
+	GroupID    int64
+	NickName   string
+	CreateDate time.Time `db:"createDate"` // db tag 优先
+	secret     string
+}
+
+func TestSqliteNamingStrategy(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS member;
+		CREATE TABLE member
+		(
+		  member_id  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  group_id   INTEGER NOT NULL DEFAULT '0',
+		  nick_name  TEXT    NOT NULL DEFAULT '',
+		  createDate DATETIME         DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.NamingStrategy = dbx.SnakeCase
+	defer func() { db.NamingStrategy = nil }()
+	db.Bind("member", &Member{}, false)
+
+	now := time.Now()
+	id, err := db.Table("member").Insert(&Member{GroupID: 2, NickName: "jack", CreateDate: now, secret: "x"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, int64(1))
+
+	m := &Member{}
+	err = db.Table("member").Where("group_id=?", 2).One(m)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, m.MemberID, int64(1))
+	assert.Equal(t, m.NickName, "jack")
+	assert.Equal(t, m.CreateDate.Unix(), now.Unix())
+	assert.Equal(t, m.secret, "")
+
+	assert.Equal(t, dbx.SnakeCase("HTTPServerID"), "http_server_id")
+	assert.Equal(t, dbx.CamelCase("CreateDate"), "createDate")
+	assert.Equal(t, dbx.CamelCase("ID"), "id")
+}
+
+type Profile struct {
+	Pid      int64          `db:"pid"`
+	Nick     *string        `db:"nick"`
+	Age      *int64         `db:"age"`
+	Bio      sql.NullString `db:"bio"`
+	Score    sql.NullInt64  `db:"score"`
+	Birthday sql.NullTime   `db:"birthday"`
+	LoginAt  *time.Time     `db:"login_at"`
+}
+
+type ProfileZero struct {
+	Pid  int64  `db:"pid"`
+	Nick string `db:"nick"`
+	Age  int64  `db:"age"`
+}
+
+func TestSqliteNull(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS profile;
+		CREATE TABLE profile
+		(
+		  pid      INTEGER PRIMARY KEY AUTOINCREMENT,
+		  nick     TEXT     NULL,
+		  age      INTEGER  NULL,
+		  bio      TEXT     NULL,
+		  score    INTEGER  NULL,
+		  birthday DATETIME NULL,
+		  login_at DATETIME NULL
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("profile", &Profile{}, true)
+	db.LoadCache()
+
+	// nil 指针和 sql.Null* 写入 NULL
+	_, err = db.Table("profile").Insert(&Profile{Pid: 1})
+	assert.Equal(t, err, nil)
+	nick := "jack"
+	age := int64(18)
+	now := time.Now()
+	_, err = db.Table("profile").Insert(&Profile{Pid: 2, Nick: &nick, Age: &age, Bio: sql.NullString{String: "bio", Valid: true}, Score: sql.NullInt64{Int64: 100, Valid: true}, LoginAt: &now})
+	assert.Equal(t, err, nil)
+
+	var n int64
+	err = db.QueryRow("SELECT COUNT(*) FROM profile WHERE nick IS NULL AND age IS NULL AND bio IS NULL AND score IS NULL AND login_at IS NULL").Scan(&n)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(1))
+
+	// 走 SQL
+	p := &Profile{}
+	err = db.Table("profile").Where("pid=?", 1).One(p)
+	assert.Equal(t, err, nil)
+	assert.Assert(t, p.Nick == nil && p.Age == nil && p.LoginAt == nil)
+	assert.Equal(t, p.Bio.Valid, false)
+	assert.Equal(t, p.Score.Valid, false)
+	assert.Equal(t, p.Birthday.Valid, false)
+
+	list := []*Profile{}
+	err = db.Table("profile").Where("pid>?", 0).Sort("pid", 1).All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 2)
+	assert.Assert(t, list[0].Nick == nil)
+	assert.Equal(t, *list[1].Nick, "jack")
+	assert.Equal(t, *list[1].Age, int64(18))
+	assert.Equal(t, list[1].Bio.String, "bio")
+	assert.Equal(t, list[1].Score.Int64, int64(100))
+	assert.Equal(t, list[1].LoginAt.Unix(), now.Unix())
+
+	// 走缓存，UpdateM 写入 NULL
+	_, err = db.Table("profile").WherePK(2).UpdateM(dbx.M{{"nick", nil}, {"score", nil}})
+	assert.Equal(t, err, nil)
+	err = db.Table("profile").WherePK(2).One(p)
+	assert.Equal(t, err, nil)
+	assert.Assert(t, p.Nick == nil)
+	assert.Equal(t, p.Score.Valid, false)
+	assert.Equal(t, *p.Age, int64(18))
+	err = db.Table("profile").Where("pid=?", 2).One(p)
+	assert.Equal(t, err, nil)
+	assert.Assert(t, p.Nick == nil)
+	assert.Equal(t, p.Score.Valid, false)
+
+	// NULL 读入非指针字段
+	_, err = db.Exec(`DROP TABLE IF EXISTS profile_zero;
+		CREATE TABLE profile_zero AS SELECT pid, nick, age FROM profile;
+	`)
+	assert.Equal(t, err, nil)
+	pz := &ProfileZero{}
+	err = db.Table("profile_zero").Where("pid=?", 1).One(pz)
+	assert.Assert(t, err != nil)
+	db.NullToZero = true
+	defer func() { db.NullToZero = false }()
+	err = db.Table("profile_zero").Where("pid=?", 1).One(pz)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, pz.Nick, "")
+	assert.Equal(t, pz.Age, int64(0))
+}
+
+// 实现了 sql.Scanner / driver.Valuer，存储为 "x,y"
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("Point: unsupported type %T", src)
+	}
+	_, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+func (p Point) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d,%d", p.X, p.Y), nil
+}
+
+type Place struct {
+	Id  int64  `db:"id"`
+	Pos Point  `db:"pos"`
+	IP  net.IP `db:"ip"`
+}
+
+func TestSqliteConverter(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS place;
+		CREATE TABLE place
+		(
+		  id  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  pos TEXT NOT NULL DEFAULT '',
+		  ip  TEXT NOT NULL DEFAULT ''
+		);
+	`)
+	assert.Equal(t, err, nil)
+
+	dbx.RegisterConverter(reflect.TypeOf(""), reflect.TypeOf(net.IP{}), func(v interface{}) (interface{}, error) {
+		return net.ParseIP(v.(string)), nil
+	})
+	dbx.RegisterWriteConverter(reflect.TypeOf(net.IP{}), reflect.TypeOf(""), func(v interface{}) (interface{}, error) {
+		return v.(net.IP).String(), nil
+	})
+
+	_, err = db.Table("place").Insert(&Place{Id: 1, Pos: Point{3, 4}, IP: net.ParseIP("192.168.0.1")})
+	assert.Equal(t, err, nil)
+
+	var pos, ip string
+	err = db.QueryRow("SELECT pos, ip FROM place WHERE id=1").Scan(&pos, &ip)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, pos, "3,4")
+	assert.Equal(t, ip, "192.168.0.1")
+
+	p := &Place{}
+	err = db.Table("place").WherePK(1).One(p)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p.Pos, Point{3, 4})
+	assert.Equal(t, p.IP.String(), "192.168.0.1")
+
+	_, err = db.Table("place").WherePK(1).UpdateM(dbx.M{{"pos", Point{5, 6}}, {"ip", net.ParseIP("10.0.0.1")}})
+	assert.Equal(t, err, nil)
+	list := []Place{}
+	err = db.Table("place").All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, list[0].Pos, Point{5, 6})
+	assert.Equal(t, list[0].IP.String(), "10.0.0.1")
+
+	// 只注册了读的转换，写入时不转换
+	dbx.RegisterConverter(reflect.TypeOf(Host("")), reflect.TypeOf(net.IP{}), func(v interface{}) (interface{}, error) {
+		return net.ParseIP(string(v.(Host))), nil
+	})
+	_, err = db.Table("place").WherePK(1).UpdateM(dbx.M{{"ip", Host("10.0.0.2")}})
+	assert.Equal(t, err, nil)
+	err = db.QueryRow("SELECT ip FROM place WHERE id=1").Scan(&ip)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ip, "10.0.0.2")
+
+	// 导出、导入 CSV 同样按照注册的转换，二进制的列为 base64
+	buf := &strings.Builder{}
+	_, err = db.Table("place").ExportCSV(buf)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, buf.String(), "id,pos,ip\n1,\"5,6\",10.0.0.2\n")
+	_, err = db.Exec("DELETE FROM place")
+	assert.Equal(t, err, nil)
+	res, err := db.Table("place").ImportCSV(strings.NewReader(buf.String()), dbx.ImportOpts{KeepAutoIncrement: true})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(res.Errors), 0)
+	err = db.Table("place").WherePK(1).One(p)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p.Pos, Point{5, 6})
+	assert.Equal(t, p.IP.String(), "10.0.0.2")
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS attachment;
+		CREATE TABLE attachment
+		(
+		  id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		  data BLOB NULL
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("attachment", &Attachment{}, false)
+	_, err = db.Table("attachment").Insert(&Attachment{Data: []byte{0, 1, 0xff}})
+	assert.Equal(t, err, nil)
+	buf.Reset()
+	_, err = db.Table("attachment").ExportCSV(buf)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, buf.String(), "id,data\n1,AAH/\n")
+	res, err = db.Table("attachment").ImportCSV(strings.NewReader("id,data\n2,AAH/\n3,!\n"), dbx.ImportOpts{KeepAutoIncrement: true})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res.Rows, int64(1))
+	assert.Equal(t, res.Errors[0].Line, 3)
+	a := &Attachment{}
+	err = db.Table("attachment").WherePK(2).One(a)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, a.Data, []byte{0, 1, 0xff})
+}
+
+type Host string
+
+type Attachment struct {
+	Id   int64  `db:"id"`
+	Data []byte `db:"data"`
+}
+
+type Event struct {
+	At        time.Time `db:"at,pk,time=ms"`
+	Name      string    `db:"name"`
+	UpdatedAt time.Time `db:"updated_at,time=unixmilli"`
+	Stamp     time.Time `db:"stamp,time=rfc3339|utc|us"`
+}
+
+func TestSqliteTimePolicy(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS event;
+		CREATE TABLE event
+		(
+		  at         DATETIME NOT NULL PRIMARY KEY,
+		  name       TEXT     NOT NULL DEFAULT '',
+		  updated_at INTEGER  NOT NULL DEFAULT '0',
+		  stamp      TEXT     NOT NULL DEFAULT ''
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("event", &Event{}, true)
+	db.LoadCache()
+
+	now := time.Now()
+	_, err = db.Table("event").Insert(&Event{At: now, Name: "start", UpdatedAt: now, Stamp: now})
+	assert.Equal(t, err, nil)
+
+	var updatedAt int64
+	var stamp string
+	err = db.QueryRow("SELECT updated_at, stamp FROM event").Scan(&updatedAt, &stamp)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, updatedAt, now.UnixNano()/int64(time.Millisecond))
+	assert.Equal(t, stamp, now.UTC().Truncate(time.Microsecond).Format("2006-01-02T15:04:05.000000Z07:00"))
+
+	// 走 SQL，精度和时区保留
+	e := &Event{}
+	err = db.Table("event").Where("name=?", "start").One(e)
+	assert.Equal(t, err, nil)
+	assert.Assert(t, e.At.Equal(now.Truncate(time.Millisecond)))
+	assert.Assert(t, e.UpdatedAt.Equal(now.Truncate(time.Millisecond)))
+	assert.Assert(t, e.Stamp.Equal(now.Truncate(time.Microsecond)))
+	assert.Equal(t, e.Stamp.Location(), time.UTC)
+
+	// 重新加载缓存，time.Time 主键的 key 与 WherePK() 一致
+	db.Table("event").LoadCache()
+	e = &Event{}
+	err = db.Table("event").WherePK(now).One(e)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, e.Name, "start")
+
+	n, err := db.Table("event").WherePK(now).Delete()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(1))
+	err = db.Table("event").WherePK(now).One(e)
+	assert.Equal(t, err, dbx.ErrNoRows)
+
+	// 默认的策略不转换时区：写入值本身的时区，读出时不按照 time.Local 解释
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*3600)
+	defer func() { time.Local = local }()
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("UTC+8", 8*3600))
+	_, err = db.Table("event").Insert(&Event{At: at, Name: "tz", UpdatedAt: at, Stamp: at})
+	assert.Equal(t, err, nil)
+	var s string
+	err = db.QueryRow("SELECT at || '' FROM event WHERE name='tz'").Scan(&s)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s, "2024-01-02 03:04:05.000")
+	e = &Event{}
+	err = db.Table("event").Where("name=?", "tz").One(e)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, e.At.Format("2006-01-02 15:04:05"), "2024-01-02 03:04:05")
+	assert.Assert(t, e.At.Location() != time.Local)
+}
+
+type AccountMeta struct {
+	Plan  string `json:"plan"`
+	Quota []int  `json:"quota"`
+}
+
+type Account struct {
+	Id       int64                  `db:"id"`
+	Meta     AccountMeta            `db:"meta,json"`
+	Settings map[string]interface{} `db:"settings,json"`
+}
+
+func TestSqliteJSON(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS account;
+		CREATE TABLE account
+		(
+		  id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		  meta     TEXT NOT NULL DEFAULT '{}',
+		  settings TEXT NOT NULL DEFAULT '{}'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("account", &Account{}, false)
+
+	plans := []string{"free", "pro", "pro"}
+	for i, plan := range plans {
+		a := &Account{Id: int64(i + 1), Meta: AccountMeta{plan, []int{i * 10}}, Settings: map[string]interface{}{"dark": i%2 == 0}}
+		_, err = db.Table("account").Insert(a)
+		assert.Equal(t, err, nil)
+	}
+
+	var meta string
+	err = db.QueryRow("SELECT meta FROM account WHERE id=2").Scan(&meta)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, meta, `{"plan":"pro","quota":[10]}`)
+
+	a := &Account{}
+	err = db.Table("account").WherePK(3).One(a)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, a.Meta, AccountMeta{"pro", []int{20}})
+	assert.Equal(t, a.Settings["dark"], true)
+
+	check := func() {
+		list := []Account{}
+		err = db.Table("account").WhereJSON("meta", "$.plan", "=", "pro").Sort("id", -1).All(&list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(list), 2)
+		assert.Equal(t, list[0].Id, int64(3))
+
+		n, err := db.Table("account").WhereJSON("meta", "$.plan", "=", "pro").WhereJSON("meta", "$.quota[0]", ">", 10).Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(1))
+
+		err = db.Table("account").WhereJSON("settings", "$.dark", "=", true).Sort("id", 1).One(a)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, a.Id, int64(1))
+
+		err = db.Table("account").WhereJSON("meta", "$.none", "=", "x").One(a)
+		assert.Equal(t, err, dbx.ErrNoRows)
+	}
+
+	// 走 SQL
+	check()
+
+	// 走缓存：删除数据库中的数据，结果不变
+	db.Bind("account", &Account{}, true)
+	db.Table("account").LoadCache()
+	_, err = db.Exec("DELETE FROM account")
+	assert.Equal(t, err, nil)
+	check()
+
+	// 缓存中数组不能与数字比较，返回错误，而不是按照字符串比较
+	_, err = db.Table("account").WhereJSON("meta", "$.quota", ">", 1).Count()
+	assert.ErrorContains(t, err, "can not compare")
+}
+
+// 两位小数的定点数，代替 decimal.Decimal
+type Dec struct {
+	n int64
+}
+
+func (d Dec) Add(d2 Dec) Dec { return Dec{d.n + d2.n} }
+func (d Dec) Sub(d2 Dec) Dec { return Dec{d.n - d2.n} }
+func (d Dec) Cmp(d2 Dec) int {
+	if d.n < d2.n {
+		return -1
+	} else if d.n > d2.n {
+		return 1
+	}
+	return 0
+}
+func (d Dec) String() string { return fmt.Sprintf("%d.%02d", d.n/100, d.n%100) }
+
+func (d *Dec) Scan(src interface{}) error {
+	var f float64
+	_, err := fmt.Sscanf(fmt.Sprintf("%v", src), "%g", &f)
+	d.n = int64(f*100 + 0.5)
+	return err
+}
+
+func (d Dec) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+type Wallet struct {
+	Id      int64   `db:"id"`
+	Balance float64 `db:"balance"`
+	Points  uint64  `db:"points"`
+	Quota   int64   `db:"quota"`
+	Amount  Dec     `db:"amount"`
+	Ratio   float32 `db:"ratio"`
+	Level   int8    `db:"level"`
+}
+
+func TestSqliteUpdateMArithmetic(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS wallet;
+		CREATE TABLE wallet
+		(
+		  id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		  balance REAL    NOT NULL DEFAULT '0',
+		  points  INTEGER NOT NULL DEFAULT '0',
+		  quota   INTEGER NOT NULL DEFAULT '0',
+		  amount  TEXT    NOT NULL DEFAULT '0',
+		  ratio   TEXT    NOT NULL DEFAULT '0',
+		  level   INTEGER NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("wallet", &Wallet{}, true)
+	db.LoadCache()
+
+	_, err = db.Table("wallet").Insert(&Wallet{Id: 1, Balance: 10, Points: 10, Quota: 10, Amount: Dec{1000}, Ratio: 1.5, Level: 100})
+	assert.Equal(t, err, nil)
+
+	_, err = db.Table("wallet").WherePK(1).UpdateM(dbx.M{{"balance+", 1.5}, {"points-", 3}, {"quota/", 4}, {"amount+", "2.25"}})
+	assert.Equal(t, err, nil)
+	_, err = db.Table("wallet").WherePK(1).UpdateM(dbx.M{{"balance<", 2}, {"quota>", 5}, {"amount>", "1"}})
+	assert.Equal(t, err, nil)
+	_, err = db.Table("wallet").WherePK(1).UpdateM(dbx.M{{"quota/", 0}})
+	assert.Assert(t, err != nil)
+	// 小数的 % 与 math.Mod() 相同
+	_, err = db.Table("wallet").WherePK(1).UpdateM(dbx.M{{"balance%", 0.75}})
+	assert.Equal(t, err, nil)
+	// 超出字段类型的范围时报错，缓存和数据库都不修改
+	_, err = db.Table("wallet").WherePK(1).UpdateM(dbx.M{{"quota+", 1}, {"level+", 100}})
+	assert.ErrorContains(t, err, "out of range")
+	_, err = db.Table("wallet").WherePK(1).UpdateM(dbx.M{{"points-", 8}})
+	assert.ErrorContains(t, err, "out of range")
+
+	want := Wallet{Id: 1, Balance: 0.5, Points: 7, Quota: 5, Amount: Dec{1225}, Ratio: 1.5, Level: 100}
+
+	// 缓存和数据库的结果一致
+	w := Wallet{}
+	err = db.Table("wallet").WherePK(1).One(&w)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, w, want)
+
+	w = Wallet{}
+	err = db.Table("wallet").Where("id=?", 1).One(&w)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, w, want)
+}
+
+type Blogger struct {
+	Uid   int64       `db:"uid"`
+	Name  string      `db:"name"`
+	Posts []*BlogPost `dbx:"hasmany:blog_post,fk=uid"`
+}
+
+type BlogPost struct {
+	Id      int64    `db:"id"`
+	Uid     int64    `db:"uid"`
+	Title   string   `db:"title"`
+	Blogger *Blogger `dbx:"belongsto:blogger,fk=uid"`
+}
+
+func TestSqliteRelation(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS blogger;
+		CREATE TABLE blogger
+		(
+		  uid  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  name TEXT NOT NULL DEFAULT ''
+		);
+		DROP TABLE IF EXISTS blog_post;
+		CREATE TABLE blog_post
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  uid   INTEGER NOT NULL DEFAULT '0',
+		  title TEXT    NOT NULL DEFAULT ''
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("blogger", &Blogger{}, false)
+	db.Bind("blog_post", &BlogPost{}, false)
+
+	for i := int64(1); i <= 3; i++ {
+		_, err = db.Table("blogger").Insert(&Blogger{Uid: i, Name: fmt.Sprintf("b%v", i)})
+		assert.Equal(t, err, nil)
+	}
+	// uid=3 没有文章
+	for i, uid := range []int64{2, 1, 2, 1, 2} {
+		_, err = db.Table("blog_post").Insert(&BlogPost{Id: int64(i + 1), Uid: uid, Title: fmt.Sprintf("p%v", i+1)})
+		assert.Equal(t, err, nil)
+	}
+
+	titles := func(posts []*BlogPost) []string {
+		ret := []string{}
+		for _, p := range posts {
+			ret = append(ret, p.Title)
+		}
+		return ret
+	}
+
+	// 走 SQL，子表只查询一次
+	list := []Blogger{}
+	err = db.Table("blogger").Preload("Posts").Sort("uid", 1).All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 3)
+	assert.DeepEqual(t, titles(list[0].Posts), []string{"p2", "p4"})
+	assert.DeepEqual(t, titles(list[1].Posts), []string{"p1", "p3", "p5"})
+	assert.DeepEqual(t, titles(list[2].Posts), []string{})
+
+	posts := []*BlogPost{}
+	err = db.Table("blog_post").Preload("Blogger").Sort("id", 1).All(&posts)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, posts[0].Blogger.Name, "b2")
+	assert.Equal(t, posts[1].Blogger.Name, "b1")
+
+	b, err := dbx.T[Blogger](db, "blogger").Preload("Posts").ByPK(1)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, titles(b.Posts), []string{"p2", "p4"})
+
+	err = db.Table("blogger").Preload("None").WherePK(1).One(b)
+	assert.Assert(t, err != nil)
+
+	// 走缓存：删除数据库中的数据，结果不变
+	db.Bind("blogger", &Blogger{}, true)
+	db.Bind("blog_post", &BlogPost{}, true)
+	db.Table("blogger").LoadCache()
+	db.Table("blog_post").LoadCache()
+	_, err = db.Exec("DELETE FROM blogger; DELETE FROM blog_post")
+	assert.Equal(t, err, nil)
+
+	b = &Blogger{}
+	err = db.Table("blogger").Preload("Posts").WherePK(2).One(b)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, titles(b.Posts), []string{"p1", "p3", "p5"})
+
+	// 返回的是缓存的拷贝
+	b.Posts[0].Title = "changed"
+	p := &BlogPost{}
+	err = db.Table("blog_post").Preload("Blogger").WherePK(1).One(p)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p.Title, "p1")
+	assert.Equal(t, p.Blogger.Name, "b2")
+	assert.Assert(t, p.Blogger.Posts == nil)
+}
+
+type Grp struct {
+	Gid  int64  `db:"gid"`
+	Name string `db:"name"`
+}
+
+type UserGrp struct {
+	User `db:"u"`
+	Grp  `db:"g"`
+}
+
+type UserReport struct {
+	Uid       int64   `db:"uid"`
+	GroupName *string `db:"gname"`
+}
+
+func TestSqliteJoin(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS grp;
+		CREATE TABLE grp
+		(
+		  gid  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  name TEXT NOT NULL DEFAULT ''
+		);
+		INSERT INTO grp (gid, name) VALUES (1, 'admin'), (2, 'member');
+	`)
+	assert.Equal(t, err, nil)
+	for i, gid := range []int64{1, 2, 9} {
+		_, err = db.Table("user").Insert(&User{Uid: int64(i + 1), Gid: gid, Name: fmt.Sprintf("u%v", i+1), CreateDate: time.Now()})
+		assert.Equal(t, err, nil)
+	}
+
+	// 同名的列按照别名区分
+	list := []UserGrp{}
+	err = db.Table("user").As("u").InnerJoin("grp g", "g.gid=u.gid").Sort("u.uid", 1).All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].User.Name, "u1")
+	assert.Equal(t, list[0].Grp.Name, "admin")
+	assert.Equal(t, list[1].User.Name, "u2")
+	assert.Equal(t, list[1].Grp.Name, "member")
+
+	// ON 中的参数在 WHERE 之前
+	ug := &UserGrp{}
+	err = db.Table("user").As("u").Join("grp g", "g.gid=u.gid AND g.name<>?", "admin").Where("u.uid>?", 1).One(ug)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ug.User.Uid, int64(2))
+	assert.Equal(t, ug.Grp.Name, "member")
+
+	err = db.Table("user").As("u").Join("grp g", "g.gid=u.gid").WherePK(1).One(ug)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ug.Grp.Name, "admin")
+
+	// LEFT JOIN 没有匹配时为 NULL
+	reports := []UserReport{}
+	err = db.Table("user").LeftJoin("grp", "grp.gid=user.gid").Fields("user.uid", "grp.name AS gname").Sort("user.uid", 1).All(&reports)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(reports), 3)
+	assert.Equal(t, *reports[0].GroupName, "admin")
+	assert.Assert(t, reports[2].GroupName == nil)
+
+	n, err := db.Table("user").As("u").LeftJoin("grp g", "g.gid=u.gid").WhereM(dbx.M{{"g.name", "member"}}).Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(1))
+}
+
+type Order struct {
+	Id     int64   `db:"id"`
+	Gid    int64   `db:"gid"`
+	Amount float64 `db:"amount"`
+}
+
+type OrderStat struct {
+	Gid   int64   `db:"gid"`
+	N     int64   `db:"n"`
+	Total float64 `db:"total"`
+	Avg   float64 `db:"avg_amount"`
+}
+
+func TestSqliteGroupBy(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS orders;
+		CREATE TABLE orders
+		(
+		  id     INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid    INTEGER NOT NULL DEFAULT '0',
+		  amount REAL    NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("orders", &Order{}, false)
+
+	amounts := map[int64][]float64{1: {1.5, 2.25}, 2: {10.5}, 3: {1, 2, 3}}
+	for gid := int64(1); gid <= 3; gid++ {
+		for _, amount := range amounts[gid] {
+			_, err = db.Table("orders").Insert(&Order{Gid: gid, Amount: amount})
+			assert.Equal(t, err, nil)
+		}
+	}
+
+	check := func() {
+		list := []OrderStat{}
+		err = db.Table("orders").Select("gid", dbx.Count("*").As("n"), dbx.Sum("amount").As("total"), dbx.Avg("amount")).
+			GroupBy("gid").Having("n>?", 1).Sort("total", -1).All(&list)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, list, []OrderStat{{3, 3, 6, 2}, {1, 2, 3.75, 1.875}})
+
+		maps := []map[string]interface{}{}
+		err = db.Table("orders").Select("gid", dbx.Max("amount")).GroupBy("gid").All(&maps)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(maps), 3)
+		assert.Equal(t, maps[1]["gid"], int64(2))
+		assert.Equal(t, maps[1]["max_amount"], 10.5)
+
+		// 浮点数的 SUM 不会被截断
+		stat := &OrderStat{}
+		err = db.Table("orders").Select(dbx.Sum("amount").As("total")).WhereM(dbx.M{{"gid", 1}}).One(stat)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, stat.Total, 3.75)
+
+		avg, err := db.Table("orders").Avg("amount")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, avg, 3.375)
+	}
+
+	// 走 SQL
+	check()
+
+	// 走缓存：删除数据库中的数据，结果不变
+	db.Bind("orders", &Order{}, true)
+	db.Table("orders").LoadCache()
+	_, err = db.Exec("DELETE FROM orders")
+	assert.Equal(t, err, nil)
+	check()
+}
+
+type Reading struct {
+	Id    int64           `db:"id"`
+	Gid   int64           `db:"gid"`
+	Cnt   sql.NullInt64   `db:"cnt"`
+	Val   sql.NullFloat64 `db:"val"`
+	Level *int64          `db:"level"`
+}
+
+// 可以为 NULL 的列：缓存中的聚合跳过 NULL，类型与 SQL 相同
+func TestSqliteGroupByNull(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS reading;
+		CREATE TABLE reading
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid   INTEGER NOT NULL DEFAULT '0',
+		  cnt   INTEGER NULL,
+		  val   REAL    NULL,
+		  level INTEGER NULL
+		);
+		INSERT INTO reading (id, gid, cnt, val, level) VALUES (1, 1, 2, 1.5, 5), (2, 1, NULL, NULL, NULL), (3, 1, 3, 2.0, 7), (4, 2, NULL, NULL, NULL);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("reading", &Reading{}, false)
+
+	check := func() {
+		maps := []map[string]interface{}{}
+		err = db.Table("reading").Select("gid", dbx.Count("cnt"), dbx.Sum("cnt"), dbx.Max("cnt"), dbx.Sum("val"), dbx.Min("val"), dbx.Max("val"), dbx.Max("level")).
+			GroupBy("gid").Sort("gid", 1).All(&maps)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, maps, []map[string]interface{}{
+			{"gid": int64(1), "count_cnt": int64(2), "sum_cnt": int64(5), "max_cnt": int64(3), "sum_val": 3.5, "min_val": 1.5, "max_val": 2.0, "max_level": int64(7)},
+			{"gid": int64(2), "count_cnt": int64(0), "sum_cnt": nil, "max_cnt": nil, "sum_val": nil, "min_val": nil, "max_val": nil, "max_level": nil},
+		})
+
+		// sql.NullInt64 按照数据库中的值比较，NULL 不匹配
+		list, err := db.Table("reading").Fields("id").WhereM(dbx.M{{"cnt>", 2}}).AllMaps()
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, list, []map[string]interface{}{{"id": int64(3)}})
+		list, err = db.Table("reading").Fields("cnt").WhereM(dbx.M{{"gid", 1}}).Sort("cnt", -1).AllMaps()
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, list, []map[string]interface{}{{"cnt": int64(3)}, {"cnt": int64(2)}, {"cnt": nil}})
+	}
+
+	// 走 SQL
+	check()
+
+	// 走缓存：删除数据库中的数据，结果不变
+	db.Bind("reading", &Reading{}, true)
+	db.Table("reading").LoadCache()
+	_, err = db.Exec("DELETE FROM reading")
+	assert.Equal(t, err, nil)
+	check()
+}
+
+type Gadget struct {
+	Id    int64          `db:"id"`
+	Name  string         `db:"name"`
+	Price float64        `db:"price"`
+	Note  *string        `db:"note"`
+	Qty   int32          `db:"qty"`
+	Code  sql.NullString `db:"code"`
+}
+
+func TestSqliteMaps(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS gadget;
+		CREATE TABLE gadget
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  name  TEXT NOT NULL DEFAULT '',
+		  price REAL NOT NULL DEFAULT '0',
+		  note  TEXT NULL,
+		  qty   INTEGER NOT NULL DEFAULT '0',
+		  code  TEXT NULL
+		);
+		INSERT INTO gadget (id, name, price, note, qty, code) VALUES (1, 'a', 1.5, 'x', 1, 'c1'), (2, 'b', 2.5, NULL, 2, NULL), (3, 'c', 3.5, 'z', 3, 'c3');
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("gadget", &Gadget{}, false)
+
+	check := func() {
+		list, err := db.Table("gadget").WhereM(dbx.M{{"name", "b"}}).AllMaps()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(list), 1)
+		assert.Equal(t, list[0]["id"], int64(2))
+		assert.Equal(t, list[0]["price"], 2.5)
+		assert.Equal(t, list[0]["note"], nil)
+
+		// 缓存与数据库返回相同的类型：整数为 int64，NULL 为 nil
+		list, err = db.Table("gadget").WhereM(dbx.M{{"id", 3}}).AllMaps()
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, list, []map[string]interface{}{
+			{"id": int64(3), "name": "c", "price": 3.5, "note": "z", "qty": int64(3), "code": "c3"},
+		})
+		list, err = db.Table("gadget").Fields("qty", "code").WhereM(dbx.M{{"id", 2}}).AllMaps()
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, list, []map[string]interface{}{{"qty": int64(2), "code": nil}})
+
+		names := []string{}
+		err = db.Table("gadget").Sort("id", -1).Pluck("name", &names)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, names, []string{"c", "b", "a"})
+
+		notes := []*string{}
+		err = db.Table("gadget").Pluck("note", &notes)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, *notes[0], "x")
+		assert.Assert(t, notes[1] == nil)
+
+		keyed := map[int64]*Gadget{}
+		err = db.Table("gadget").AllKeyed(&keyed)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(keyed), 3)
+		assert.Equal(t, keyed[3].Name, "c")
+
+		ok, err := db.Table("gadget").WhereM(dbx.M{{"name", "c"}}).Exists()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, ok, true)
+		ok, err = db.Table("gadget").WhereM(dbx.M{{"name", "none"}}).Exists()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, ok, false)
+		ok, err = db.Table("gadget").WherePK(2).Exists()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, ok, true)
+	}
+
+	// 走 SQL
+	check()
+
+	// 走缓存：删除数据库中的数据，结果不变
+	db.Bind("gadget", &Gadget{}, true)
+	db.Table("gadget").LoadCache()
+	_, err = db.Exec("DELETE FROM gadget")
+	assert.Equal(t, err, nil)
+	check()
+
+	// 有 SQL 字符串的条件查询数据库
+	ok, err := db.Table("gadget").Where("id>?", 0).Exists()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ok, false)
+}
+
+type Item struct {
+	Id  int64 `db:"id"`
+	Gid int64 `db:"gid"`
+}
+
+func TestSqliteIter(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS item;
+		CREATE TABLE item
+		(
+		  id  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid INTEGER NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("item", &Item{}, false)
+	for i := int64(1); i <= 50; i++ {
+		_, err = db.Table("item").Insert(&Item{Id: i, Gid: i % 2})
+		assert.Equal(t, err, nil)
+	}
+
+	it, err := db.Table("item").WhereM(dbx.M{{"gid", 1}}).Iter(context.Background())
+	assert.Equal(t, err, nil)
+	n, sum := 0, int64(0)
+	for it.Next() {
+		item := &Item{}
+		assert.Equal(t, it.Scan(item), nil)
+		n++
+		sum += item.Id
+	}
+	assert.Equal(t, it.Err(), nil)
+	assert.Equal(t, n, 25)
+	assert.Equal(t, sum, int64(625))
+
+	// 提前结束
+	ids := []int64{}
+	err = db.Table("item").Iterate(context.Background(), func(item *Item) error {
+		ids = append(ids, item.Id)
+		if len(ids) == 3 {
+			return dbx.ErrStop
+		}
+		return nil
+	})
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, ids, []int64{1, 2, 3})
+
+	// 取消 context
+	ctx, cancel := context.WithCancel(context.Background())
+	n = 0
+	err = dbx.T[Item](db, "item").Iterate(ctx, func(item *Item) error {
+		n++
+		if n == 5 {
+			cancel()
+		}
+		return nil
+	})
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, n, 5)
+
+	// 按照主键分批
+	sizes := []int{}
+	last := int64(0)
+	err = db.Table("item").Where("gid=? OR id<?", 0, 10).Chunk(context.Background(), 10, func(list []*Item) error {
+		sizes = append(sizes, len(list))
+		for _, item := range list {
+			assert.Assert(t, item.Id > last)
+			last = item.Id
+		}
+		return nil
+	})
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, sizes, []int{10, 10, 10})
+
+	chunks := 0
+	err = dbx.T[Item](db, "item").Chunk(context.Background(), 20, func(list []Item) error {
+		chunks++
+		return nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, chunks, 3)
+}
+
+func TestSqlitePage(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS item;
+		CREATE TABLE item
+		(
+		  id  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid INTEGER NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("item", &Item{}, false)
+	for i := int64(1); i <= 25; i++ {
+		_, err = db.Table("item").Insert(&Item{Id: i, Gid: i % 3})
+		assert.Equal(t, err, nil)
+	}
+
+	// 按照 gid DESC, id ASC 翻页，拼起来与一次查询的结果相同
+	check := func() {
+		want := []Item{}
+		err = db.Table("item").Where("id>?", 0).Sort("gid", -1).Sort("id", 1).All(&want)
+		assert.Equal(t, err, nil)
+
+		got := []Item{}
+		cursor, pages := "", 0
+		for {
+			items, next, err := dbx.T[Item](db, "item").WhereM(dbx.M{}).Sort("gid", -1).Page(cursor, 10)
+			assert.Equal(t, err, nil)
+			got = append(got, items...)
+			pages++
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		assert.Equal(t, pages, 3)
+		assert.DeepEqual(t, got, want)
+
+		// 排序不同的游标报错
+		_, _, err = dbx.T[Item](db, "item").Page(cursor, 10)
+		assert.Assert(t, err != nil)
+	}
+
+	// 走 SQL
+	check()
+
+	// 走缓存
+	db.Bind("item", &Item{}, true)
+	db.Table("item").LoadCache()
+	check()
+
+	// 可以为 NULL 的列不能排序
+	_, _, err = dbx.T[User](db, "user").Sort("name", 1).Page("", 10)
+	assert.ErrorContains(t, err, "can be NULL")
+}
+
+func TestSqlitePaginate(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS item;
+		CREATE TABLE item
+		(
+		  id  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid INTEGER NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("item", &Item{}, false)
+	for i := int64(1); i <= 25; i++ {
+		_, err = db.Table("item").Insert(&Item{Id: i, Gid: i % 2})
+		assert.Equal(t, err, nil)
+	}
+
+	// Count() 之后同一个 *Query 可以继续查询
+	q := db.Table("item").Where("gid=?", 1).Sort("id", -1)
+	n, err := q.Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(13))
+	list := []*Item{}
+	err = q.All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 13)
+	assert.Equal(t, list[0].Id, int64(25))
+
+	check := func() {
+		list := []*Item{}
+		p, err := db.Table("item").Sort("id", 1).Paginate(2, 10, &list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p, dbx.Pagination{Page: 2, PerPage: 10, Total: 25, TotalPages: 3, HasNext: true, HasPrev: true})
+		assert.Equal(t, len(list), 10)
+		assert.Equal(t, list[0].Id, int64(11))
+
+		// 带条件，最后一页
+		items, p, err := dbx.T[Item](db, "item").Where("gid=?", 0).Sort("id", 1).Paginate(3, 5)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p, dbx.Pagination{Page: 3, PerPage: 5, Total: 12, TotalPages: 3, HasNext: false, HasPrev: true})
+		assert.Equal(t, len(items), 2)
+		assert.Equal(t, items[1].Id, int64(24))
+
+		// 超出范围返回空列表
+		items, p, err = dbx.T[Item](db, "item").Paginate(9, 10)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(items), 0)
+		assert.Equal(t, p.HasNext, false)
+	}
+
+	// 走 SQL
+	check()
+
+	// 走缓存
+	db.Bind("item", &Item{}, true)
+	db.Table("item").LoadCache()
+	check()
+}
+
+func TestSqliteScope(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS item;
+		CREATE TABLE item
+		(
+		  id  INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid INTEGER NOT NULL DEFAULT '0'
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("item", &Item{}, false)
+	for i := int64(1); i <= 10; i++ {
+		_, err = db.Table("item").Insert(&Item{Id: i, Gid: i % 2})
+		assert.Equal(t, err, nil)
+	}
+
+	// 链式调用不修改 base
+	base := db.Table("item").Where("gid=?", 1)
+	list := []*Item{}
+	err = base.Where("id>?", 5).Sort("id", -1).Limit(2).All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].Id, int64(9))
+	n, err := base.Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(5))
+	list = []*Item{}
+	err = base.All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 5)
+
+	// 并发共享 base
+	wg := sync.WaitGroup{}
+	for i := int64(0); i < 10; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			exists, err := base.Where("id=?", id).Exists()
+			assert.Equal(t, err, nil)
+			assert.Equal(t, exists, id%2 == 1)
+		}(i)
+	}
+	wg.Wait()
+
+	// Clone() 之后修改不影响原来的查询
+	q := base.Clone()
+	n, err = q.Where("id<?", 4).Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(2))
+	n, err = q.Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(5))
+
+	// 命名的条件
+	db.Scope("odd", func(q *dbx.Query) *dbx.Query { return q.Where("gid=?", 1) })
+	db.Scope("recent", func(q *dbx.Query) *dbx.Query { return q.Where("id>?", 6).Sort("id", -1) })
+	list = []*Item{}
+	err = db.Table("item").Scopes("odd", "recent").All(&list)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].Id, int64(9))
+	items, err := dbx.T[Item](db, "item").Scopes("recent").All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(items), 4)
+
+	// 不存在的 scope
+	func() {
+		defer func() {
+			assert.Assert(t, recover() != nil)
+		}()
+		db.Table("item").Scopes("nope")
+	}()
+}
+
+type Player struct {
+	Id    int64  `db:"id"`
+	Gid   int64  `db:"gid"`
+	Score int64  `db:"score"`
+	Name  string `db:"name"`
+}
+
+func TestSqliteExpr(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS vip;
+		CREATE TABLE vip
+		(
+		  id INTEGER PRIMARY KEY
+		);
+		INSERT INTO vip (id) VALUES (2), (4);
+	`)
+	assert.Equal(t, err, nil)
+
+	sqliteEachCache(t, `DROP TABLE IF EXISTS player;
+		CREATE TABLE player
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid   INTEGER NOT NULL DEFAULT '0',
+		  score INTEGER NOT NULL DEFAULT '0',
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+	`, dbx.M{{"player", &Player{}}}, func() {
+		for i := int64(1); i <= 5; i++ {
+			_, err = db.Table("player").Insert(&Player{Id: i, Gid: i % 2, Score: i * 10, Name: fmt.Sprintf("p%v", i)})
+			assert.Equal(t, err, nil)
+		}
+
+		// 子查询
+		list := []*Player{}
+		err = db.Table("player").Where("id IN (?)", db.Table("vip").Fields("id")).All(&list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(list), 2)
+		list = []*Player{}
+		err = db.Table("player").WhereM(dbx.M{{"id NOT IN", db.Table("vip").Fields("id")}, {"score >", 10}}).All(&list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(list), 2)
+		assert.Equal(t, list[0].Id, int64(3))
+
+		// WhereM 的运算符，缓存中执行
+		list = []*Player{}
+		err = db.Table("player").WhereM(dbx.M{{"id IN", []int64{1, 3, 5}}, {"score <=", 30}}).Sort("id", -1).All(&list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(list), 2)
+		assert.Equal(t, list[0].Id, int64(3))
+		n, err := db.Table("player").WhereM(dbx.M{{"name LIKE", "p%"}, {"id IN", []int64{}}}).Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(0))
+
+		// Fields / Sort 中的表达式
+		m, err := db.Table("player").Fields("id", dbx.Expr("score*? AS double", 2)).WherePK(2).AllMaps()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, m[0]["double"], int64(40))
+		list = []*Player{}
+		err = db.Table("player").Sort(dbx.Expr("CASE WHEN id=? THEN 0 ELSE 1 END", 4), 1).Sort("id", 1).All(&list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, list[0].Id, int64(4))
+		assert.Equal(t, list[1].Id, int64(1))
+
+		// UpdateM 中的表达式，缓存从数据库重新读取
+		_, err = db.Table("player").WhereM(dbx.M{{"gid", 1}}).UpdateM(dbx.M{{"score", dbx.Expr("MAX(score,?)", 25)}, {"name", dbx.Raw("UPPER(name)")}})
+		assert.Equal(t, err, nil)
+		p := &Player{}
+		err = db.Table("player").WherePK(1).One(p)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Score, int64(25))
+		assert.Equal(t, p.Name, "P1")
+		err = db.Table("player").WherePK(5).One(p)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Score, int64(50))
+		_, err = db.Table("player").WherePK(2).UpdateM(dbx.M{{"score", db.Table("vip").Fields(dbx.Raw("MAX(id)"))}})
+		assert.Equal(t, err, nil)
+		err = db.Table("player").WherePK(2).One(p)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Score, int64(4))
+	})
+}
+
+type Rank struct {
+	Id    int64  `db:"id"`
+	Score *int64 `db:"score"`
+	Name  string `db:"name"`
+}
+
+func TestSqliteSort(t *testing.T) {
+
+	initSqlite()
+
+	// Pluck() 在开启缓存时在内存中排序
+	ids := func(q *dbx.Query) []int64 {
+		arr := []int64{}
+		err := q.Pluck("id", &arr)
+		assert.Equal(t, err, nil)
+		return arr
+	}
+	score := func(n int64) *int64 { return &n }
+
+	sqliteEachCache(t, `DROP TABLE IF EXISTS rank;
+		CREATE TABLE rank
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  score INTEGER NULL,
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+	`, dbx.M{{"rank", &Rank{}}}, func() {
+		rows := []*Rank{{1, score(20), "b"}, {2, nil, "A"}, {3, score(10), "a"}, {4, nil, "c"}, {5, score(20), "B"}}
+		for _, r := range rows {
+			_, err = db.Table("rank").Insert(r)
+			assert.Equal(t, err, nil)
+		}
+
+		// NULL 默认最小，缓存与数据库一致
+		assert.DeepEqual(t, ids(db.Table("rank").Sort("score", dbx.SORT_DESC).Sort("id", dbx.SORT_ASC)), []int64{1, 5, 3, 2, 4})
+		assert.DeepEqual(t, ids(db.Table("rank").Sort("score", dbx.SORT_ASC).Sort("id", dbx.SORT_DESC)), []int64{4, 2, 3, 5, 1})
+
+		// NULLS FIRST / LAST
+		assert.DeepEqual(t, ids(db.Table("rank").Sort("score", dbx.SORT_ASC, dbx.NULLS_LAST).Sort("id", dbx.SORT_ASC)), []int64{3, 1, 5, 2, 4})
+		assert.DeepEqual(t, ids(db.Table("rank").Sort("score", dbx.SORT_DESC, dbx.NULLS_FIRST).Sort("id", dbx.SORT_ASC)), []int64{2, 4, 1, 5, 3})
+
+		// SQLite 的字符串区分大小写
+		assert.DeepEqual(t, ids(db.Table("rank").Sort("name", dbx.SORT_ASC)), []int64{2, 5, 3, 1, 4})
+
+		// 用户输入的排序，不在 allowed 中的忽略
+		assert.DeepEqual(t, ids(db.Table("rank").SortBy("-score, name;DROP TABLE rank,+id", "score", "id")), []int64{1, 5, 3, 2, 4})
+		items, err := dbx.T[Rank](db, "rank").SortBy("-id", "id").All()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, items[0].Id, int64(5))
+
+		// 非法的列名、不存在的列返回错误，不会拼接到 SQL 中
+		list := []*Rank{}
+		err = db.Table("rank").Sort("id; DROP TABLE rank", 1).All(&list)
+		assert.Assert(t, err != nil)
+		err = db.Table("rank").Sort("nope", 1).All(&list)
+		assert.Assert(t, err != nil)
+		n, err := db.Table("rank").Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(5))
+	})
+}
+
+func TestSqliteIdent(t *testing.T) {
+
+	initSqlite()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS rank;
+		CREATE TABLE rank
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  score INTEGER NULL,
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+		INSERT INTO rank (score, name) VALUES (10, 'a'), (20, 'b'), (30, 'c');
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("rank", &Rank{}, false)
+
+	// 非法的名字返回 *dbx.IdentifierError，可以用 errors.Is / errors.As 判断
+	isIdentErr := func(err error, kind string) *dbx.IdentifierError {
+		assert.Assert(t, errors.Is(err, dbx.ErrInvalidIdentifier), "%v", err)
+		var e *dbx.IdentifierError
+		assert.Assert(t, errors.As(err, &e))
+		assert.Equal(t, e.Kind, kind)
+		return e
+	}
+	list := []*Rank{}
+	isIdentErr(db.Table("rank; DROP TABLE rank").All(&list), "table")
+	isIdentErr(db.Table("rank").Fields("id FROM rank; --").All(&list), "column")
+	isIdentErr(db.Table("rank").Fields("id AS `x`").All(&list), "alias")
+	isIdentErr(db.Table("rank").WhereM(dbx.M{{"name = '' OR 1=1 --", 1}}).All(&list), "column")
+	_, err = db.Table("rank").Sum("score); DROP TABLE rank; --")
+	isIdentErr(err, "column")
+	_, err = db.Table("rank").UpdateM(dbx.M{{"score=0,name", "x"}})
+	isIdentErr(err, "column")
+
+	// 表结构已知时，不存在的列也返回错误
+	_, err = db.Table("rank").Max("nope")
+	e := isIdentErr(err, "column")
+	assert.Equal(t, e.Table, "rank")
+
+	// 合法的名字：列名、表名.列名、别名
+	maps, err := db.Table("rank").Fields("rank.id", "name AS n").Sort("n", dbx.SORT_DESC).AllMaps()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(maps), 3)
+	assert.Equal(t, maps[0]["n"], "c")
+	n, err := db.Table("rank").Sum("score")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, int64(60))
+
+	// 需要拼接 SQL 时显式使用 dbx.Raw()
+	maps, err = db.Table("rank").Fields(dbx.Raw("COUNT(*) AS n"), dbx.Raw("MAX(score) - ? AS m", 5)).AllMaps()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, maps[0]["n"], int64(3))
+	assert.Equal(t, maps[0]["m"], int64(25))
+
+	// 表没有被修改
+	cnt, err := db.Table("rank").Count()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cnt, int64(3))
+
+	// Bind() 的表名来自数据库，合法的带引号的名字不报错
+	_, err = db.Exec(`DROP TABLE IF EXISTS "rank-log";
+		CREATE TABLE "rank-log"
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  score INTEGER,
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("rank-log", &Rank{}, true)
+	db.Table("rank-log").LoadCache()
+	_, err = db.Table("rank-log").Insert(&Rank{Name: "x"})
+	assert.Equal(t, err, nil)
+	r := &Rank{}
+	err = db.Table("rank-log").Where("name=?", "x").One(r)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, r.Id, int64(1))
+}
+
+func TestSqliteInsertBatch(t *testing.T) {
+
+	initSqlite()
+
+	sqliteEachCache(t, `DROP TABLE IF EXISTS player;
+		CREATE TABLE player
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid   INTEGER NOT NULL DEFAULT 0,
+		  score INTEGER NOT NULL DEFAULT 0,
+		  name  TEXT NOT NULL DEFAULT '' UNIQUE
+		);
+	`, dbx.M{{"player", &Player{}}}, func() {
+		// 超过 SQLite 默认 999 个参数，分成多条 SQL
+		list := []*Player{}
+		for i := 0; i < 1000; i++ {
+			list = append(list, &Player{Gid: int64(i % 3), Score: int64(i), Name: fmt.Sprintf("p%v", i)})
+		}
+		ids, err := db.Table("player").InsertBatch(list)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(ids), 1000)
+		for i, id := range ids {
+			assert.Equal(t, id, int64(i+1))
+			assert.Equal(t, list[i].Id, id)
+		}
+		n, err := db.Table("player").Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(1000))
+		p := &Player{}
+		err = db.Table("player").WherePK(ids[999]).One(p)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Name, "p999")
+
+		// []T，ID 写回 slice 中的元素
+		vals := []Player{{Gid: 1, Name: "a"}, {Gid: 2, Name: "b"}}
+		ids, err = db.Table("player").InsertBatch(vals, dbx.BatchOpts{Size: 1})
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, ids, []int64{1001, 1002})
+		assert.Equal(t, vals[1].Id, int64(1002))
+		err = db.Table("player").WherePK(1002).One(p)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Name, "b")
+
+		// Ignore：跳过冲突的行，不返回 ID
+		ids, err = db.Table("player").InsertBatch([]*Player{{Name: "a"}, {Name: "c"}}, dbx.BatchOpts{Ignore: true})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(ids), 0)
+		ok, err := db.Table("player").WhereM(dbx.M{{"name", "c"}}).Exists()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, ok, true)
+		n, err = db.Table("player").Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(1003))
+
+		// 冲突时返回错误
+		_, err = db.Table("player").InsertBatch([]*Player{{Name: "d"}, {Name: "a"}})
+		assert.Assert(t, err != nil)
+
+		// 泛型
+		ids, err = dbx.T[Player](db, "player").InsertBatch([]*Player{{Name: "e"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(ids), 1)
+		e, err := dbx.T[Player](db, "player").ByPK(ids[0])
+		assert.Equal(t, err, nil)
+		assert.Equal(t, e.Name, "e")
+
+		// 空的 slice
+		ids, err = db.Table("player").InsertBatch([]Player{})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(ids), 0)
+	})
+}
+
+func TestSqliteUpsert(t *testing.T) {
+
+	initSqlite()
+
+	sqliteEachCache(t, `DROP TABLE IF EXISTS player;
+		CREATE TABLE player
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid   INTEGER NOT NULL DEFAULT 0,
+		  score INTEGER NOT NULL DEFAULT 0,
+		  name  TEXT NOT NULL DEFAULT '' UNIQUE
+		);
+	`, dbx.M{{"player", &Player{}}}, func() {
+		get := func(id int64) *Player {
+			p := &Player{}
+			err := db.Table("player").WherePK(id).One(p)
+			assert.Equal(t, err, nil)
+			return p
+		}
+
+		// 按照唯一索引，插入
+		p := &Player{Gid: 1, Score: 10, Name: "a"}
+		inserted, err := db.Table("player").Upsert(p, dbx.OnConflict("name").Update("score"))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, inserted, true)
+		assert.Equal(t, p.Id, int64(1))
+
+		// 冲突，只更新 score，返回已有的行的 ID
+		p = &Player{Gid: 2, Score: 20, Name: "a"}
+		inserted, err = db.Table("player").Upsert(p, dbx.OnConflict("name").Update("score"))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, inserted, false)
+		assert.Equal(t, p.Id, int64(1))
+		assert.DeepEqual(t, get(1), &Player{Id: 1, Gid: 1, Score: 20, Name: "a"})
+
+		// 默认按照主键，更新其他的列
+		inserted, err = dbx.T[Player](db, "player").Upsert(&Player{Id: 1, Gid: 5, Score: 30, Name: "a2"}, dbx.OnConflict())
+		assert.Equal(t, err, nil)
+		assert.Equal(t, inserted, false)
+		assert.DeepEqual(t, get(1), &Player{Id: 1, Gid: 5, Score: 30, Name: "a2"})
+
+		// M，值可以为表达式
+		inserted, err = db.Table("player").UpsertM(dbx.M{{"name", "b"}, {"score", dbx.Expr("?+1", 1)}}, dbx.OnConflict("name"))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, inserted, true)
+		inserted, err = db.Table("player").UpsertM(dbx.M{{"name", "b"}, {"score", 7}}, dbx.OnConflict("name"))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, inserted, false)
+		// 自增 ID 在冲突时也会被消耗，按照 name 查询
+		p = &Player{}
+		err = db.Table("player").WhereM(dbx.M{{"name", "b"}}).One(p)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, p, &Player{Id: p.Id, Score: 7, Name: "b"})
+		n, err := db.Table("player").Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(2))
+
+		// 没有冲突的条件返回错误
+		_, err = db.Table("player").Upsert(&Player{Name: "c"}, nil)
+		assert.ErrorContains(t, err, "conflict is nil")
+		_, err = db.Table("player").UpsertM(dbx.M{{"name", "c"}}, nil)
+		assert.ErrorContains(t, err, "conflict is nil")
+
+		// 冲突的列、更新的列必须在插入的列中
+		_, err = db.Table("player").Upsert(&Player{Name: "c"}, dbx.OnConflict("nope"))
+		assert.Assert(t, err != nil)
+		_, err = db.Table("player").UpsertM(dbx.M{{"name", "c"}}, dbx.OnConflict("name").Update("gid"))
+		assert.Assert(t, err != nil)
+
+		// Replace() 返回插入的 ID
+		id, err := db.Table("player").Replace(&Player{Id: 10, Name: "z"})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, id, int64(10))
+	})
+}
+
+func TestSqliteUpsertReload(t *testing.T) {
+
+	initSqlite()
+
+	// 触发器修改了冲突的列，按照 name 找不到写入的行，重新加载整个表
+	_, err = db.Exec(`DROP TABLE IF EXISTS grp;
+		CREATE TABLE grp
+		(
+		  gid  INTEGER NOT NULL PRIMARY KEY,
+		  name TEXT NOT NULL DEFAULT '' UNIQUE
+		);
+		CREATE TRIGGER grp_upper AFTER INSERT ON grp BEGIN
+		  UPDATE grp SET name=upper(name) WHERE gid=NEW.gid;
+		END;
+	`)
+	assert.Equal(t, err, nil)
+	db.Bind("grp", &Grp{}, true)
+	db.Table("grp").LoadCache()
+
+	inserted, err := db.Table("grp").Upsert(&Grp{Gid: 1, Name: "a"}, dbx.OnConflict("name"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, inserted, true)
+
+	g := &Grp{}
+	err = db.Table("grp").WherePK(1).One(g)
+	assert.Equal(t, err, nil)
+	assert.DeepEqual(t, g, &Grp{Gid: 1, Name: "A"})
+}
+
+type Follow struct {
+	Uid  int64  `db:"uid"`
+	Fid  int64  `db:"fid"`
+	Note string `db:"note"`
+}
+
+func TestSqliteBulkByPK(t *testing.T) {
+
+	initSqlite()
+
+	sqliteEachCache(t, `DROP TABLE IF EXISTS player;
+		CREATE TABLE player
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  gid   INTEGER NOT NULL DEFAULT 0,
+		  score INTEGER NOT NULL DEFAULT 0,
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+		DROP TABLE IF EXISTS follow;
+		CREATE TABLE follow
+		(
+		  uid  INTEGER NOT NULL,
+		  fid  INTEGER NOT NULL,
+		  note TEXT NOT NULL DEFAULT '',
+		  PRIMARY KEY (uid, fid)
+		);
+	`, dbx.M{{"player", &Player{}}, {"follow", &Follow{}}}, func() {
+		list := []*Player{}
+		for i := 0; i < 10; i++ {
+			list = append(list, &Player{Gid: 1, Name: fmt.Sprintf("p%v", i)})
+		}
+		_, err = db.Table("player").InsertBatch(list)
+		assert.Equal(t, err, nil)
+
+		// 批量更新，不存在的行不计入
+		for _, p := range list {
+			p.Score = p.Id * 10
+		}
+		list[9].Name = "last"
+		n, err := db.Table("player").UpdateBatch(append(list, &Player{Id: 100, Name: "nope"}))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(10))
+		p := &Player{}
+		err = db.Table("player").WherePK(10).One(p)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, p, &Player{Id: 10, Gid: 1, Score: 100, Name: "last"})
+		sum, err := db.Table("player").Sum("score")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, sum, int64(550))
+		// 没有匹配的行不进入缓存
+		err = db.Table("player").WherePK(100).One(p)
+		assert.Equal(t, err, dbx.ErrNoRows)
+
+		// []T，泛型
+		n, err = db.Table("player").UpdateBatch([]Player{{Id: 1, Gid: 2, Name: "first"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(1))
+		n, err = dbx.T[Player](db, "player").UpdateBatch([]*Player{{Id: 2, Gid: 2, Name: "second"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(1))
+		names := []string{}
+		err = db.Table("player").WhereM(dbx.M{{"gid", 2}}).Pluck("name", &names)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, names, []string{"first", "second"})
+
+		// 批量删除，可以传 slice
+		n, err = db.Table("player").DeleteByPKs(1, 2, 3)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(3))
+		n, err = dbx.T[Player](db, "player").DeleteByPKs([]int64{4, 5, 100})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(2))
+		err = db.Table("player").WherePK(4).One(p)
+		assert.Equal(t, err, dbx.ErrNoRows)
+		cnt, err := db.Table("player").Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, cnt, int64(5))
+
+		// 不能与其他条件同时使用
+		_, err = db.Table("player").WhereM(dbx.M{{"gid", 1}}).DeleteByPKs(6)
+		assert.Assert(t, err != nil)
+
+		// 联合主键
+		_, err = db.Table("follow").InsertBatch([]Follow{{1, 2, "a"}, {1, 3, "b"}, {2, 1, "c"}})
+		assert.Equal(t, err, nil)
+		n, err = db.Table("follow").UpdateBatch([]Follow{{1, 3, "b2"}, {2, 1, "c2"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(2))
+		f := &Follow{}
+		err = db.Table("follow").WherePK(2, 1).One(f)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, f.Note, "c2")
+		n, err = db.Table("follow").DeleteByPKs([]interface{}{1, 2}, []interface{}{2, 1})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(2))
+		notes := []string{}
+		err = db.Table("follow").Pluck("note", &notes)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, notes, []string{"b2"})
+		_, err = db.Table("follow").DeleteByPKs(1)
+		assert.Assert(t, err != nil)
+	})
+}
+
+func TestSqliteExportImport(t *testing.T) {
+
+	initSqlite()
+
+	score := func(n int64) *int64 { return &n }
+
+	sqliteEachCache(t, `DROP TABLE IF EXISTS rank;
+		CREATE TABLE rank
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  score INTEGER NULL,
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+		DROP TABLE IF EXISTS rank2;
+		CREATE TABLE rank2
+		(
+		  id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		  score INTEGER NULL,
+		  name  TEXT NOT NULL DEFAULT ''
+		);
+	`, dbx.M{{"rank", &Rank{}}, {"rank2", &Rank{}}}, func() {
+		rows := []*Rank{{1, score(10), "a,b"}, {2, nil, "say \"hi\""}, {5, score(-3), ""}}
+		_, err = db.Table("rank").InsertBatch(rows)
+		assert.Equal(t, err, nil)
+		// InsertBatch() 由数据库生成自增 ID
+		assert.Equal(t, rows[2].Id, int64(3))
+
+		// CSV，NULL 为空字符串，自增 ID 保留
+		buf := &strings.Builder{}
+		n, err := db.Table("rank").ExportCSV(buf)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(3))
+		assert.Equal(t, buf.String(), "id,score,name\n1,10,\"a,b\"\n2,,\"say \"\"hi\"\"\"\n3,-3,\n")
+		res, err := db.Table("rank2").ImportCSV(strings.NewReader(buf.String()), dbx.ImportOpts{KeepAutoIncrement: true})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, res.Rows, int64(3))
+		assert.Equal(t, len(res.Errors), 0)
+		list := []*Rank{}
+		err = db.Table("rank2").All(&list)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, list, rows)
+
+		// JSON lines，按照条件、列导出
+		buf.Reset()
+		n, err = db.Table("rank").WhereM(dbx.M{{"id <", 3}}).Fields("name", "score").ExportJSONL(buf)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, n, int64(2))
+		assert.Equal(t, buf.String(), `{"name":"a,b","score":10}`+"\n"+`{"name":"say \"hi\"","score":null}`+"\n")
+
+		// 冲突时更新；出错的行报告行号，其他的行继续导入
+		jsonl := `{"id":1,"name":"a2","score":11}
+
+{"id":6,"name":"f"}
+{"id":"x"}
+{"id":7,"nope":1}
+{"Id":8,"Name":"h"}
+`
+		res, err = db.Table("rank2").ImportJSONL(strings.NewReader(jsonl), dbx.ImportOpts{BatchOpts: dbx.BatchOpts{OnConflict: dbx.OnConflict()}, KeepAutoIncrement: true})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, res.Rows, int64(3))
+		assert.Equal(t, len(res.Errors), 2)
+		assert.Equal(t, res.Errors[0].Line, 4)
+		assert.Equal(t, res.Errors[1].Line, 5)
+		assert.Assert(t, errors.Is(res.Errors[1], dbx.ErrInvalidIdentifier))
+		r := &Rank{}
+		err = db.Table("rank2").WherePK(1).One(r)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, r, &Rank{1, score(11), "a2"})
+		cnt, err := db.Table("rank2").Count()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, cnt, int64(5))
+
+		// 一批中有冲突的行：逐行重试，其他的行写入
+		csv1 := "id,name\n9,i\n1,dup\n10,j\nx,k\n"
+		res, err = db.Table("rank2").ImportCSV(strings.NewReader(csv1), dbx.ImportOpts{BatchOpts: dbx.BatchOpts{Size: 10}, KeepAutoIncrement: true})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, res.Rows, int64(2))
+		assert.Equal(t, len(res.Errors), 2)
+		assert.Equal(t, res.Errors[0].Line, 3)
+		assert.Equal(t, res.Errors[1].Line, 5)
+		names := []string{}
+		err = db.Table("rank2").Sort("id", dbx.SORT_ASC).Pluck("name", &names)
+		assert.Equal(t, err, nil)
+		assert.DeepEqual(t, names, []string{"a2", "say \"hi\"", "", "f", "h", "i", "j"})
+
+		// Ignore，MaxErrors
+		res, err = db.Table("rank2").ImportCSV(strings.NewReader("id;name\n1;z\n11;k\n"), dbx.ImportOpts{BatchOpts: dbx.BatchOpts{Ignore: true}, Comma: ';', KeepAutoIncrement: true})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, res.Rows, int64(2))
+		ok, err := db.Table("rank2").WhereM(dbx.M{{"name", "k"}}).Exists()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, ok, true)
+		_, err = db.Table("rank2").ImportCSV(strings.NewReader("id\nx\ny\n"), dbx.ImportOpts{MaxErrors: 1})
+		assert.Assert(t, err != nil)
+
+		// 不存在的列
+		_, err = db.Table("rank2").ImportCSV(strings.NewReader("id,nope\n1,2\n"))
+		assert.Assert(t, errors.Is(err, dbx.ErrInvalidIdentifier))
+	})
+}
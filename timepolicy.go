@@ -0,0 +1,210 @@
+package dbx
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// time.Time 的存储格式
+const (
+	TIME_DATETIME   = iota // "2006-01-02 15:04:05"，默认；Cassandra 为 timestamp
+	TIME_RFC3339           // "2006-01-02T15:04:05+08:00"，带时区的文本
+	TIME_UNIX              // 整数，秒
+	TIME_UNIX_MILLI        // 整数，毫秒
+)
+
+// time.Time 的读写策略，DB.TimePolicy 为默认值，可以被列的 db tag 覆盖：
+//
+//	db.TimePolicy = dbx.TimePolicy{Location: time.UTC, Precision: 3}
+//	Created time.Time `db:"created,time=unixmilli"`
+//	Updated time.Time `db:"updated,time=rfc3339|local|us"`
+//
+// time= 的取值：datetime / rfc3339 / unix / unixmilli，utc / local，s / ms / us / ns，用 | 分隔。
+type TimePolicy struct {
+	Format    int
+	Location  *time.Location // 写入前转换到该时区，读出时按照该时区解释没有时区的值；nil 不转换，写入值本身的时区
+	Precision int            // 秒的小数位数 0-9，TIME_UNIX / TIME_UNIX_MILLI 忽略
+}
+
+// 列的 db tag 中的 time=，-1 / nil 表示使用 DB.TimePolicy
+type colTimePolicy struct {
+	format    int
+	location  *time.Location
+	precision int
+}
+
+func parse_time_tag(s string) (*colTimePolicy, error) {
+	p := &colTimePolicy{format: -1, precision: -1}
+	for _, v := range strings.Split(s, "|") {
+		switch strings.TrimSpace(v) {
+		case "datetime":
+			p.format = TIME_DATETIME
+		case "rfc3339":
+			p.format = TIME_RFC3339
+		case "unix":
+			p.format = TIME_UNIX
+		case "unixmilli":
+			p.format = TIME_UNIX_MILLI
+		case "utc":
+			p.location = time.UTC
+		case "local":
+			p.location = time.Local
+		case "s":
+			p.precision = 0
+		case "ms":
+			p.precision = 3
+		case "us":
+			p.precision = 6
+		case "ns":
+			p.precision = 9
+		default:
+			return nil, dbxErrorNew("unknown time option: %v", v)
+		}
+	}
+	return p, nil
+}
+
+// 合并 DB 和列的策略
+func (t *TableStruct) time_policy(col *Col) TimePolicy {
+	var p TimePolicy
+	if t.db != nil {
+		p = t.db.TimePolicy
+	}
+	if col != nil && col.timePolicy != nil {
+		if col.timePolicy.format != -1 {
+			p.Format = col.timePolicy.format
+		}
+		if col.timePolicy.location != nil {
+			p.Location = col.timePolicy.location
+		}
+		if col.timePolicy.precision != -1 {
+			p.Precision = col.timePolicy.precision
+		}
+	}
+	return p
+}
+
+// Location 为 nil 时不转换，保持值本身的时区
+func (p TimePolicy) in(t time.Time) time.Time {
+	if p.Location == nil {
+		return t
+	}
+	return t.In(p.Location)
+}
+
+func (p TimePolicy) layout() string {
+	layout := "2006-01-02 15:04:05"
+	if p.Format == TIME_RFC3339 {
+		layout = "2006-01-02T15:04:05"
+	}
+	if p.Precision > 0 && p.Precision <= 9 {
+		layout += "." + strings.Repeat("0", p.Precision)
+	}
+	if p.Format == TIME_RFC3339 {
+		layout += "Z07:00"
+	}
+	return layout
+}
+
+// 写入：time.Time -> 数据库的值，主键的值和缓存的 key 也使用这个值
+func (p TimePolicy) to_db(t time.Time, isCQL bool) interface{} {
+	switch p.Format {
+	case TIME_UNIX:
+		return t.Unix()
+	case TIME_UNIX_MILLI:
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	t = p.in(t)
+	if p.Precision < 9 {
+		d := time.Second
+		for i := 0; i < p.Precision; i++ {
+			d /= 10
+		}
+		t = t.Truncate(d)
+	}
+	if isCQL && p.Format == TIME_DATETIME {
+		return t
+	}
+	return t.Format(p.layout())
+}
+
+// 读出：数据库的值 -> time.Time
+func (p TimePolicy) from_db(src interface{}, isCQL bool) (time.Time, bool) {
+	switch v := src.(type) {
+	case time.Time:
+		if p.Format == TIME_DATETIME && !isCQL && p.Location != nil {
+			// 没有时区的值被驱动解释为 UTC（或者 DSN 中的 loc），按照写入时的时区还原
+			return time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), p.Location), true
+		}
+		return p.in(v), true
+	case int64:
+		switch p.Format {
+		case TIME_UNIX:
+			return p.in(time.Unix(v, 0)), true
+		case TIME_UNIX_MILLI:
+			return p.in(time.Unix(0, v*int64(time.Millisecond))), true
+		}
+	case []byte:
+		return p.from_db(uint8_to_string(v), isCQL)
+	case string:
+		switch p.Format {
+		case TIME_UNIX, TIME_UNIX_MILLI:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return p.from_db(n, isCQL)
+		case TIME_RFC3339:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return p.in(t), err == nil
+		default:
+			// 没有时区的文本，nil 时与驱动相同按照 UTC 解释
+			loc := time.UTC
+			if p.Location != nil {
+				loc = p.Location
+			}
+			t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", v, loc)
+			return t, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (t *TableStruct) isCQL() bool {
+	return t.db != nil && t.db.isCQL
+}
+
+// 缓存 key 中第 i 个主键的值，time.Time 按照列的策略格式化，保证 WherePK() 与读出的行一致
+func pk_key_value(tableStruct *TableStruct, i int, v interface{}) interface{} {
+	if tm, ok := v.(time.Time); ok && i < len(tableStruct.PrimaryKey) {
+		col := tableStruct.ColFieldMap.GetByColName(tableStruct.PrimaryKey[i])
+		return tableStruct.time_policy(col).to_db(tm, false)
+	}
+	return v
+}
+
+// WherePK() 的参数对应的缓存 key
+func get_pk_key_by_args(tableStruct *TableStruct, args []interface{}) string {
+	keys := make([]interface{}, len(args))
+	for i, v := range args {
+		keys[i] = pk_key_value(tableStruct, i, v)
+	}
+	return get_key_str_by_args(keys...)
+}
+
+// WherePK() 的参数转换为数据库的值
+func pk_args_to_db(tableStruct *TableStruct, args []interface{}, isCQL bool) []interface{} {
+	ret := make([]interface{}, len(args))
+	for i, v := range args {
+		if tm, ok := v.(time.Time); ok && i < len(tableStruct.PrimaryKey) {
+			col := tableStruct.ColFieldMap.GetByColName(tableStruct.PrimaryKey[i])
+			v = tableStruct.time_policy(col).to_db(tm, isCQL)
+		}
+		ret[i] = v
+	}
+	return ret
+}
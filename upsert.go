@@ -0,0 +1,317 @@
+package dbx
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 插入，冲突时更新部分列，不删除原来的行（与 Replace() 不同）：
+//
+//	inserted, err := db.Table("user").Upsert(u, dbx.OnConflict("uid").Update("name", "gid"))
+//	inserted, err := db.Table("user").UpsertM(dbx.M{{"uid", 1}, {"name", "jack"}}, dbx.OnConflict("uid"))
+//
+// MySQL 为 INSERT ... ON DUPLICATE KEY UPDATE，SQLite 为 INSERT ... ON CONFLICT(...) DO UPDATE，
+// Cassandra 的 INSERT 本身就是覆盖，为普通的 INSERT。
+type Conflict struct {
+	cols   []string // 冲突的列，默认为主键；MySQL 由唯一索引决定，只用于更新缓存
+	update []string // 冲突时更新的列，默认为插入的列中除了冲突的列以外的列
+}
+
+func OnConflict(cols ...string) *Conflict {
+	return &Conflict{cols: cols}
+}
+
+// 冲突时更新的列，值为本次插入的值
+func (c *Conflict) Update(cols ...string) *Conflict {
+	c2 := *c
+	c2.update = cols
+	return &c2
+}
+
+// ifc 最好为 &struct，插入新行时 inserted 为 true，自增列的值写回 ifc；Cassandra 总是返回 false。
+// MySQL 按照受影响的行数判断，DSN 中设置了 clientFoundRows=true 时没有变化的行也为 1，inserted 不准确。
+func (q *Query) Upsert(ifc interface{}, c *Conflict) (inserted bool, err error) {
+	if q.readOnly {
+		return
+	}
+	defer dbxErrorDefer(&err, q)
+	if c == nil {
+		q.Panic("Upsert(): conflict is nil, use dbx.OnConflict()")
+	}
+
+	rowP := reflect.ValueOf(ifc)
+	if rowP.Kind() != reflect.Ptr {
+		rowP = reflect.New(rowP.Type())
+		rowP.Elem().Set(reflect.ValueOf(ifc))
+	}
+	if rowP.Elem().Kind() != reflect.Struct {
+		q.Panic("Upsert(): must pass a struct or struct pointer: %T", ifc)
+	}
+	tableStruct := q.getTableStruct(rowP.Type())
+
+	// 冲突的列中有自增列并且有值时插入自增列，例如按照 id upsert
+	target := c.target(tableStruct)
+	withAutoIncrement := false
+	if tableStruct.AutoIncrement != "" && in_array(tableStruct.AutoIncrement, target) {
+		pos := tableStruct.ColFieldMap.GetByColName(tableStruct.AutoIncrement).FieldPos
+		withAutoIncrement = !is_zero(get_value_from_pos(rowP.Elem(), pos))
+	}
+	colNames, args, _, _ := struct_value_to_args(tableStruct, rowP.Elem(), !withAutoIncrement, false, q.isCQL, true)
+
+	var id int64
+	inserted, id, err = q.upsert(tableStruct, colNames, args, c)
+	if err != nil {
+		return
+	}
+	if id != 0 {
+		pos := tableStruct.ColFieldMap.GetByColName(tableStruct.AutoIncrement).FieldPos
+		set_value_to_ifc(get_reflect_value_from_pos(rowP, pos), id)
+	}
+	return
+}
+
+// 按照 M 插入，表必须已经 Bind()；值可以为 dbx.Expr()
+func (q *Query) UpsertM(m M, c *Conflict) (inserted bool, err error) {
+	if q.readOnly {
+		return
+	}
+	defer dbxErrorDefer(&err, q)
+	if c == nil {
+		q.Panic("UpsertM(): conflict is nil, use dbx.OnConflict()")
+	}
+
+	tableStruct := q.getTableStruct()
+	if tableStruct == nil {
+		q.Panic("UpsertM(): table %v is not bound", q.table)
+	}
+	colNames := make([]string, 0, len(m))
+	args := make([]interface{}, 0, len(m))
+	for _, kv := range m {
+		col := tableStruct.ColFieldMap.GetByColName(kv.Key)
+		if col == nil {
+			panic(dbxErrorWrap(&IdentifierError{Kind: "column", Name: kv.Key, Table: q.table}))
+		}
+		v := kv.Value
+		switch {
+		case is_sql_value(v):
+		case col.JSON:
+			v = json_marshal(v)
+		default:
+			v = value_to_arg(v, q.isCQL)
+			if vtime, ok := v.(time.Time); ok {
+				v = tableStruct.time_policy(col).to_db(vtime, q.isCQL)
+			}
+		}
+		colNames = append(colNames, kv.Key)
+		args = append(args, v)
+	}
+	inserted, _, err = q.upsert(tableStruct, colNames, args, c)
+	return
+}
+
+// 冲突的列
+func (c *Conflict) target(tableStruct *TableStruct) []string {
+	if len(c.cols) > 0 {
+		return c.cols
+	}
+	return tableStruct.PrimaryKey
+}
+
+// 执行 upsert，id 为插入或者更新的行的自增列的值（没有自增列、Cassandra 时为 0），然后更新缓存
+func (q *Query) upsert(tableStruct *TableStruct, colNames []string, args []interface{}, c *Conflict) (inserted bool, id int64, err error) {
+	target, update := q.conflict_columns(tableStruct, colNames, c)
+	// 冲突的列的值，用于 SQLite 判断是否存在、更新缓存
+	targetArgs := make([]interface{}, len(target))
+	for i, colName := range target {
+		n := str_arr_index(colNames, colName)
+		if is_sql_value(args[n]) {
+			q.Panic("Upsert(): conflict column %v can not be an expression", colName)
+		}
+		targetArgs[i] = args[n]
+	}
+
+	fields := arr_to_sql_add(colNames, "", ",", q.isCQL)
+	values := strings.TrimRight(strings.Repeat("?,", len(colNames)), ",")
+	sql1 := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", q.tableSQL(), fields, values)
+	sql1, args = q.expand_args(sql1, args)
+	autoIncrement := ""
+	if tableStruct.AutoIncrement != "" && !q.isCQL {
+		autoIncrement = quote_ident(tableStruct.AutoIncrement, q.isCQL)
+	}
+	where := arr_to_sql_add(target, "=?", " AND ", q.isCQL)
+
+	switch q.DriverType {
+	case DRIVER_MYSQL:
+		// 受影响的行数：1 插入，2 更新，0 没有变化（clientFoundRows=true 时为 1）
+		sql1 += q.conflict_clause(target, update, autoIncrement)
+		var result sql.Result
+		result, err = q.DB.DB.Exec(sql1, args...)
+		q.LogSQL(sql1, args...)
+		if err != nil {
+			q.ErrorSQL(err.Error(), sql1, args...)
+			return
+		}
+		var n int64
+		if n, err = result.RowsAffected(); err != nil {
+			return
+		}
+		inserted = n == 1
+		if autoIncrement != "" {
+			if id, err = result.LastInsertId(); err != nil {
+				return
+			}
+		}
+	case DRIVER_SQLITE:
+		sql1 += q.conflict_clause(target, update, autoIncrement)
+		// 先在同一个事务中查询冲突的行，判断是插入还是更新
+		fields2 := "1"
+		if autoIncrement != "" {
+			fields2 = autoIncrement
+		}
+		sql2 := fmt.Sprintf("SELECT %v FROM %v WHERE %v LIMIT 1", fields2, q.tableSQL(), where)
+		var tx *sql.Tx
+		if tx, err = q.DB.DB.Begin(); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			}
+		}()
+		var id2 int64
+		err = tx.QueryRow(sql2, targetArgs...).Scan(&id2)
+		q.LogSQL(sql2, targetArgs...)
+		if err != nil && err != sql.ErrNoRows {
+			q.ErrorSQL(err.Error(), sql2, targetArgs...)
+			return
+		}
+		inserted, err = err == sql.ErrNoRows, nil
+		var result sql.Result
+		result, err = tx.Exec(sql1, args...)
+		q.LogSQL(sql1, args...)
+		if err != nil {
+			q.ErrorSQL(err.Error(), sql1, args...)
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			return
+		}
+		if autoIncrement != "" {
+			id = id2
+			if inserted {
+				if id, err = result.LastInsertId(); err != nil {
+					return
+				}
+			}
+		}
+	default:
+		if _, err = q.Exec(sql1, args...); err != nil {
+			return
+		}
+	}
+
+	// 更新时只修改了部分列，从数据库重新读取这一行
+	if q.tableEnableCache && tableStruct.EnableCache {
+		mp, ok := q.tableData[q.table]
+		if !ok {
+			q.Panic("q.tableData[q.table]: key %v does not exists.", q.table)
+		}
+		// MySQL 冲突的可能是其他的唯一索引，没有自增列时不知道修改了哪一行，重新加载整个表
+		if id == 0 && q.DriverType == DRIVER_MYSQL {
+			q.loadTableCache(q.table)
+			return
+		}
+		if id != 0 {
+			where, targetArgs = arr_to_sql_add([]string{tableStruct.AutoIncrement}, "=?", "", q.isCQL), []interface{}{id}
+		}
+		fields2 := arr_to_sql_add(tableStruct.ColFieldMap.colArr, "", ",", q.isCQL)
+		sql2 := fmt.Sprintf("SELECT %v FROM %v WHERE %v", fields2, q.tableSQL(), where)
+		rowP := reflect.New(tableStruct.Type.Elem())
+		err = q.get_row_by_sql(rowP, tableStruct, sql2, targetArgs...)
+		if err == ErrNoRows {
+			// 写入已经成功，按照冲突的列找不到这一行，重新加载整个表
+			err = nil
+			q.loadTableCache(q.table)
+			return
+		}
+		if err != nil {
+			return
+		}
+		mp.Store(get_pk_keys(tableStruct, rowP.Elem()), rowP.Interface())
+	}
+	return
+}
+
+// 冲突的列、冲突时更新的列，必须都在插入的列中
+func (q *Query) conflict_columns(tableStruct *TableStruct, colNames []string, c *Conflict) (target []string, update []string) {
+	if c == nil {
+		q.Panic("Upsert(): conflict is nil, use dbx.OnConflict()")
+	}
+	target = c.target(tableStruct)
+	if len(target) == 0 {
+		q.Panic("Upsert(): table %v has no primary key, use dbx.OnConflict(cols...)", q.table)
+	}
+	for _, colName := range target {
+		check_ident("column", colName)
+		if !in_array(colName, colNames) {
+			q.Panic("Upsert(): conflict column %v is not inserted", colName)
+		}
+	}
+	update = c.update
+	if update == nil {
+		for _, colName := range colNames {
+			if !in_array(colName, target) && !in_array(colName, tableStruct.PrimaryKey) {
+				update = append(update, colName)
+			}
+		}
+	}
+	for _, colName := range update {
+		if !in_array(colName, colNames) {
+			q.Panic("Upsert(): update column %v is not inserted", colName)
+		}
+	}
+	return
+}
+
+// INSERT 后面的冲突处理，Cassandra 为空；autoIncrement 不为空时 MySQL 使用 LAST_INSERT_ID(col)，更新时也返回这一行的自增 ID
+func (q *Query) conflict_clause(target []string, update []string, autoIncrement string) string {
+	switch q.DriverType {
+	case DRIVER_MYSQL:
+		sets := make([]string, 0, len(update)+1)
+		for _, colName := range update {
+			col := quote_ident(colName, false)
+			sets = append(sets, col+"=VALUES("+col+")")
+		}
+		if autoIncrement != "" {
+			sets = append(sets, autoIncrement+"=LAST_INSERT_ID("+autoIncrement+")")
+		} else if len(sets) == 0 {
+			col := quote_ident(target[0], false)
+			sets = append(sets, col+"="+col)
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ",")
+	case DRIVER_SQLITE:
+		sql1 := fmt.Sprintf(" ON CONFLICT(%v)", arr_to_sql_add(target, "", ",", false))
+		if len(update) == 0 {
+			return sql1 + " DO NOTHING"
+		}
+		sets := make([]string, len(update))
+		for i, colName := range update {
+			col := quote_ident(colName, false)
+			sets[i] = col + "=excluded." + col
+		}
+		return sql1 + " DO UPDATE SET " + strings.Join(sets, ",")
+	}
+	return ""
+}
+
+func str_arr_index(arr []string, v string) int {
+	for i, v2 := range arr {
+		if v2 == v {
+			return i
+		}
+	}
+	return -1
+}